@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/judwhite/go-svc"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/config"
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	"github.com/open-dingtalk/ipaas-agent/pkg/ui"
+)
+
+const usage = `用法: ipaas-agent [全局参数] <命令> [参数]
+
+命令:
+  run                                  启动本地网关 (默认)
+  version                              输出版本信息
+  validate                             校验配置文件
+  test-connection <插件> <config_key>   检查本地配置能否连接，插件为 mysql、mssql、pgsql、oracledb、grpc、http
+  doctor                               检查钉钉凭证以及所有本地配置能否连接
+  encrypt <value>...                   加密配置值
+  config print [--redacted]            输出实际生效的配置
+  config migrate [--dry-run]           将配置文件改写为当前格式
+  audit verify [file...]               校验审计日志的签名链
+
+全局参数:
+  --config <path>        配置文件路径，默认查找 ./config.yaml 和 ./config/config.yaml
+  --log-dir <dir>        日志文件目录，默认为当前目录
+  --log-format <format>  日志格式: text、json
+  --log-level <level>    日志级别: trace、debug、info、warn、error
+`
+
+// globalOptions 所有命令共用的参数，可以写在命令之前或之后
+type globalOptions struct {
+	configFile string
+	logDir     string
+	logFormat  string
+	logLevel   string
+}
+
+func (o *globalOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.configFile, "config", o.configFile, "配置文件路径，默认查找 ./config.yaml 和 ./config/config.yaml")
+	fs.StringVar(&o.logDir, "log-dir", o.logDir, "日志文件目录，默认为当前目录")
+	fs.StringVar(&o.logFormat, "log-format", o.logFormat, "日志格式: text、json")
+	fs.StringVar(&o.logLevel, "log-level", o.logLevel, "日志级别: trace、debug、info、warn、error")
+}
+
+func (o *globalOptions) apply() error {
+	if o.configFile != "" {
+		config.SetConfigFile(o.configFile)
+	}
+	// 优先于配置文件中的 log 部分
+	return logger.SetOverride(o.logDir, o.logFormat, o.logLevel)
+}
+
+// newFlagSet 创建命令的参数集合，包含全局参数
+func (o *globalOptions) newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	o.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: ipaas-agent "+synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs 解析参数并应用全局参数，参数和位置参数可以交替出现
+func (o *globalOptions) parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if err := o.apply(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	return positional, nil
+}
+
+// runCLI 解析命令行并执行命令，返回进程退出码
+func runCLI(args []string) int {
+	opts := &globalOptions{}
+	fs := flag.NewFlagSet("ipaas-agent", flag.ContinueOnError)
+	opts.register(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	args = fs.Args()
+	command := "run"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "run":
+		return runService(opts, args)
+	case "version":
+		return runVersion(opts, args)
+	case "validate":
+		return runValidate(opts, args)
+	case "test-connection":
+		return runTestConnection(opts, args)
+	case "doctor":
+		return runDoctor(opts, args)
+	case "encrypt":
+		return runEncrypt(opts, args)
+	case "config":
+		return runConfig(opts, args)
+	case "audit":
+		return runAudit(opts, args)
+	case "help":
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "未知的命令 %q\n\n%s", command, usage)
+		return 2
+	}
+}
+
+// runService 启动本地网关，作为 Windows 服务运行时同样使用此命令
+func runService(opts *globalOptions, args []string) int {
+	fs := opts.newFlagSet("run", "run [--config path] [--log-dir dir] [--log-format format] [--log-level level]")
+	if _, err := opts.parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	prg := &program{}
+	if err := svc.Run(prg); err != nil {
+		logger.Log1.Errorf("服务运行出错: %v", err)
+		return 1
+	}
+	return 0
+}
+
+func runVersion(opts *globalOptions, args []string) int {
+	fs := opts.newFlagSet("version", "version")
+	if _, err := opts.parseArgs(fs, args); err != nil {
+		return 2
+	}
+	fmt.Printf("ipaas-agent %s\n", Version)
+	fmt.Printf("  git commit: %s\n", GitCommit)
+	fmt.Printf("  build time: %s\n", BuildTime)
+	fmt.Printf("  go version: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return 0
+}
+
+// runValidate 按启动时的流程校验配置文件，不连接服务器
+func runValidate(opts *globalOptions, args []string) int {
+	fs := opts.newFlagSet("validate", "validate [--config path]")
+	if _, err := opts.parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	path, err := config.FindConfigFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	conf, err := config.ValidateConfigFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("配置文件 %s 校验通过\n", path)
+	counts := make(map[string]int)
+	for _, p := range conf.Plugins {
+		counts[p.Type]++
+	}
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Printf("  %s: %d 个配置\n", t, counts[t])
+	}
+	return 0
+}
+
+// runTestConnection 使用插件检查 config_key 对应的本地配置能否连接
+func runTestConnection(opts *globalOptions, args []string) int {
+	fs := opts.newFlagSet("test-connection", "test-connection [--timeout 10s] <插件> <config_key>")
+	timeout := fs.Duration("timeout", 10*time.Second, "连接超时时间")
+	positional, err := opts.parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return 2
+	}
+	pluginType, configKey := positional[0], positional[1]
+	if _, ok := configv1.ClientPluginOptionsTypeMap[pluginType]; !ok {
+		fmt.Fprintf(os.Stderr, "未知的插件 %q\n", pluginType)
+		return 2
+	}
+
+	// 插件初始化的日志只写入文件
+	ui.DisableOutput()
+	if err := config.LoadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	pluginManager := plugins.NewPluginManager()
+	if err := pluginManager.LoadPlugins(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer pluginManager.CloseAll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	startTime := time.Now()
+	if err := pluginManager.TestConnection(ctx, pluginType, configKey); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s 连接失败: %v\n", pluginType, configKey, logger.RedactString(err.Error()))
+		return 1
+	}
+	fmt.Printf("%s %s 连接成功，耗时 %s\n", pluginType, configKey, time.Since(startTime).Round(time.Millisecond))
+	return 0
+}
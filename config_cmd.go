@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/config"
+	"github.com/open-dingtalk/ipaas-agent/pkg/ui"
+)
+
+const configUsage = `用法:
+  ipaas-agent config print [--config path] [--redacted]
+  ipaas-agent config migrate [--config path] [--dry-run]
+`
+
+// runConfig 配置文件相关的子命令
+func runConfig(opts *globalOptions, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+	switch args[0] {
+	case "print":
+		return runConfigPrint(opts, args[1:])
+	case "migrate":
+		return runConfigMigrate(opts, args[1:])
+	default:
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+}
+
+// runConfigPrint 输出升级格式、展开引用并解密后实际生效的配置
+func runConfigPrint(opts *globalOptions, args []string) int {
+	fs := opts.newFlagSet("config print", "config print [--config path] [--redacted]")
+	redacted := fs.Bool("redacted", false, "隐藏密码、密钥等敏感配置")
+	if _, err := opts.parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	path, err := config.FindConfigFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// 标准输出只包含配置内容
+	ui.DisableOutput()
+	content, err := config.RenderConfig(path, *redacted)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(content)
+	return 0
+}
+
+// runConfigMigrate 将旧格式的配置文件改写为当前格式，原文件备份为 <file>.bak.<时间>
+func runConfigMigrate(opts *globalOptions, args []string) int {
+	fs := opts.newFlagSet("config migrate", "config migrate [--config path] [--dry-run]")
+	dryRun := fs.Bool("dry-run", false, "只输出改写后的内容，不修改文件")
+	if _, err := opts.parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	path, err := config.FindConfigFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	changes, migrated, backup, err := config.MigrateConfigFile(path, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(os.Stderr, "%s 已是最新格式，无需迁移\n", path)
+		return 0
+	}
+	for _, change := range changes {
+		fmt.Fprintln(os.Stderr, "  "+change)
+	}
+	if *dryRun {
+		os.Stdout.Write(migrated)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "已改写 %s，原文件备份为 %s\n", path, backup)
+	return 0
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/client"
+	"github.com/open-dingtalk/ipaas-agent/pkg/config"
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	pluginsv1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/ui"
+)
+
+// errorHints 常见错误的排查建议，按顺序匹配错误信息 (忽略大小写)
+var errorHints = []struct {
+	patterns []string
+	hint     string
+}{
+	{[]string{"no such host"}, "无法解析主机名，检查地址是否正确以及 DNS 配置"},
+	{[]string{"connection refused"}, "端口没有监听，检查地址、端口以及服务是否已启动"},
+	{[]string{"network is unreachable", "no route to host"}, "网络不可达，检查本机网络和路由"},
+	{[]string{"i/o timeout", "deadline exceeded", "超时"}, "连接超时，检查网络、防火墙以及代理配置"},
+	{[]string{"x509", "certificate", "tls:"}, "TLS 证书校验失败，检查 tls、ca_file 配置"},
+	{[]string{"access denied", "password authentication failed", "login failed", "ora-01017"}, "用户名或密码错误，或账号没有访问权限"},
+	{[]string{"unknown database", "does not exist", "cannot open database"}, "数据库不存在，检查 database 配置"},
+	{[]string{"ora-12514", "ora-12505"}, "服务名或 SID 不正确，检查 service_name、sid 配置"},
+	{[]string{"未找到配置"}, "本地配置中没有该 config_key"},
+}
+
+// doctorCheck 一项检查的结果
+type doctorCheck struct {
+	name    string
+	latency time.Duration
+	err     error
+	hint    string
+}
+
+// errorHint 根据错误信息给出排查建议
+func errorHint(err error) string {
+	var guardErr *pluginsv1.HTTPGuardError
+	if errors.As(err, &guardErr) {
+		return "请求被 HTTP 访问控制拒绝，检查 auth.http 配置"
+	}
+	msg := strings.ToLower(err.Error())
+	for _, h := range errorHints {
+		for _, p := range h.patterns {
+			if strings.Contains(msg, p) {
+				return h.hint
+			}
+		}
+	}
+	return ""
+}
+
+// runDoctor 检查钉钉凭证以及所有本地配置能否连接，输出检查结果
+func runDoctor(opts *globalOptions, args []string) int {
+	fs := opts.newFlagSet("doctor", "doctor [--config path] [--timeout 10s]")
+	timeout := fs.Duration("timeout", 10*time.Second, "每项检查的超时时间")
+	if _, err := opts.parseArgs(fs, args); err != nil {
+		return 2
+	}
+
+	// 插件初始化的日志只写入文件
+	ui.DisableOutput()
+	if err := config.LoadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	pluginManager := plugins.NewPluginManager()
+	if err := pluginManager.LoadPlugins(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer pluginManager.CloseAll()
+
+	var checks []doctorCheck
+	run := func(name string, check func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		startTime := time.Now()
+		err := check(ctx)
+		result := doctorCheck{name: name, latency: time.Since(startTime), err: err}
+		if err != nil {
+			result.hint = errorHint(err)
+		}
+		checks = append(checks, result)
+	}
+
+	auth := config.GetAuthClientConfig()
+	run("钉钉凭证 "+auth.OpenAPIHost, func(ctx context.Context) error {
+		err := client.CheckCredentials(ctx, auth)
+		if err != nil && errorHint(err) == "" {
+			// 连接正常但平台拒绝
+			return fmt.Errorf("%w (检查 auth.clientID、auth.clientSecret 是否正确，应用是否已开通 Stream 模式)", err)
+		}
+		return err
+	})
+	for _, target := range pluginManager.ConnectionTargets() {
+		run(target.Type+" "+target.ConfigKey, func(ctx context.Context) error {
+			return pluginManager.TestConnection(ctx, target.Type, target.ConfigKey)
+		})
+	}
+
+	failed := 0
+	data := pterm.TableData{{"检查项", "结果", "耗时", "说明"}}
+	for _, c := range checks {
+		status, message := pterm.Green("通过"), ""
+		if c.err != nil {
+			failed++
+			status = pterm.Red("失败")
+			message = logger.RedactString(c.err.Error())
+			if c.hint != "" {
+				message += "\n" + pterm.Yellow("建议: "+c.hint)
+			}
+		}
+		data = append(data, []string{c.name, status, c.latency.Round(time.Millisecond).String(), message})
+	}
+	table, err := pterm.DefaultTable.WithHasHeader().WithData(data).Srender()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(table)
+	if failed > 0 {
+		fmt.Printf("%d 项检查失败，共 %d 项\n", failed, len(checks))
+		return 1
+	}
+	fmt.Printf("全部 %d 项检查通过\n", len(checks))
+	return 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/config"
+)
+
+// runEncrypt 加密配置值，输出可以直接写入 config.yaml 的 ENC(...) 字符串
+//
+//	ipaas-agent encrypt [--key-file secret.key] <value>...
+//
+// 未提供 value 时从标准输入逐行读取
+func runEncrypt(opts *globalOptions, args []string) int {
+	fs := opts.newFlagSet("encrypt", "encrypt [--key-file path] <value>...")
+	keyFile := fs.String("key-file", "", "加密密钥文件，默认使用 "+config.SecretKeyEnv+"、"+config.SecretKeyFileEnv+" 或 "+config.DefaultSecretKeyFile)
+	values, err := opts.parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	var key []byte
+	if *keyFile != "" {
+		key, err = config.LoadSecretKeyFile(*keyFile)
+	} else {
+		key, err = config.LoadSecretKey()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if len(values) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+				values = append(values, line)
+			}
+		}
+	}
+	for _, value := range values {
+		encrypted, err := config.EncryptSecret(key, value)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(encrypted)
+	}
+	return 0
+}
@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"os"
+	"time"
 
 	"github.com/judwhite/go-svc"
 	StreamClientLogger "github.com/open-dingtalk/dingtalk-stream-sdk-go/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/admin"
+	"github.com/open-dingtalk/ipaas-agent/pkg/audit"
 	"github.com/open-dingtalk/ipaas-agent/pkg/client"
 	"github.com/open-dingtalk/ipaas-agent/pkg/config"
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
 	"github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	"github.com/open-dingtalk/ipaas-agent/pkg/tracing"
 	"github.com/open-dingtalk/ipaas-agent/pkg/ui"
 	"github.com/sirupsen/logrus"
 
@@ -16,8 +22,11 @@ import (
 
 type program struct {
 	cli    *client.Client
+	admin  *admin.Server
 	ctx    context.Context
 	cancel context.CancelFunc
+	// 导出剩余的 span，未开启链路追踪时为 nil
+	shutdownTracing func(context.Context) error
 }
 
 var (
@@ -48,6 +57,40 @@ func (p *program) Init(env svc.Environment) error {
 	// 初始化UI
 	ui.InitUI()
 
+	// 初始化链路追踪
+	if conf := config.GetClientConfig().Tracing; conf.Enabled {
+		p.shutdownTracing, err = tracing.Setup(tracing.Options{
+			Endpoint:       conf.Endpoint,
+			Headers:        conf.Headers,
+			SampleRatio:    *conf.SampleRatio,
+			ServiceName:    conf.ServiceName,
+			ServiceVersion: Version,
+			Timeout:        time.Duration(conf.Timeout) * time.Millisecond,
+		})
+		if err != nil {
+			logger.Log1.Errorf("初始化链路追踪失败: %v", err)
+			return err
+		}
+		logger.Log1.WithField("endpoint", conf.Endpoint).Info("链路追踪已开启")
+	}
+
+	// 初始化审计日志
+	if conf := config.GetClientConfig().Audit; conf.Enabled {
+		err = audit.Setup(audit.Options{
+			File:       conf.File,
+			MaxSize:    conf.MaxSize,
+			MaxBackups: conf.MaxBackups,
+			MaxAge:     conf.MaxAge,
+			Compress:   conf.Compress,
+			HMACKey:    []byte(conf.HMACKey),
+		})
+		if err != nil {
+			logger.Log1.Errorf("初始化审计日志失败: %v", err)
+			return err
+		}
+		logger.Log1.WithField("file", conf.File).Info("审计日志已开启")
+	}
+
 	// 初始化插件
 	pluginManager := plugins.NewPluginManager()
 	err = pluginManager.LoadPlugins()
@@ -59,6 +102,20 @@ func (p *program) Init(env svc.Environment) error {
 	// 初始化客户端
 	p.ctx, p.cancel = context.WithCancel(context.Background())
 	p.cli = client.NewClient(config.GetAuthClientConfig(), pluginManager)
+
+	// 管理接口在连接前启动，连接过程中 /readyz 返回 503
+	if conf := config.GetClientConfig().Admin; conf.Enabled {
+		p.admin = admin.NewServer(admin.BuildInfo{
+			Version:   Version,
+			GitCommit: GitCommit,
+			BuildTime: BuildTime,
+		}, p.cli, pluginManager)
+		if err := p.admin.Start(conf.Address); err != nil {
+			logger.Log1.Errorf("启动管理接口失败: %v", err)
+			return err
+		}
+	}
+
 	err = p.cli.Connect(p.ctx)
 	if err != nil {
 		logger.Log1.Fatalf("连接到服务器失败: %v", err)
@@ -66,10 +123,14 @@ func (p *program) Init(env svc.Environment) error {
 	}
 
 	// 监听配置文件变化
-	go config.WatchConfig(func() {
+	go config.WatchConfig(func(diff *configv1.ConfigDiff) {
 		logger.Log1.Info("配置文件已更新")
-		// 重新加载配置
-		pluginManager.ReloadConfig()
+		// 只重新加载配置有变化的插件
+		pluginManager.ReloadConfig(diff)
+		if diff.CredentialsChanged {
+			// 失败时继续使用旧连接，错误已记录日志
+			p.cli.Reconnect(config.GetAuthClientConfig())
+		}
 	})
 
 	ui.UpdateUISuccess("初始化成功")
@@ -88,6 +149,9 @@ func (p *program) Start() error {
 
 func (p *program) Stop() error {
 	// 停止服务
+	if p.admin != nil {
+		p.admin.Close()
+	}
 	if p.cli != nil {
 		p.cli.Disconnect()
 	}
@@ -95,12 +159,17 @@ func (p *program) Stop() error {
 	if p.cancel != nil {
 		p.cancel()
 	}
+	audit.Close()
+	if p.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := p.shutdownTracing(ctx); err != nil {
+			logger.Log1.Warnf("导出链路追踪数据失败: %v", err)
+		}
+	}
 	return nil
 }
 
 func main() {
-	prg := &program{}
-	if err := svc.Run(prg); err != nil {
-		logger.Log1.Errorf("服务运行出错: %v", err)
-	}
+	os.Exit(runCLI(os.Args[1:]))
 }
@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/client"
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+	"github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+)
+
+// BuildInfo 程序的版本信息，由 main 包在编译时注入
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildTime string `json:"buildTime"`
+}
+
+// Status /status 返回的运行状态
+type Status struct {
+	BuildInfo
+	GoVersion     string                          `json:"goVersion"`
+	StartTime     time.Time                       `json:"startTime"`
+	Uptime        string                          `json:"uptime"`
+	UptimeSeconds int64                           `json:"uptimeSeconds"`
+	Ready         bool                            `json:"ready"`
+	Connection    client.ConnectionStatus         `json:"connection"`
+	Plugins       map[string]plugins.PluginStatus `json:"plugins"`
+}
+
+// Server 本地管理接口
+//
+//	/healthz 进程存活即返回 200
+//	/readyz  已连接到服务器且插件已加载时返回 200，否则返回 503
+//	/status  版本、运行时间、连接状态以及各插件的状态
+//	/metrics Prometheus 格式的指标
+type Server struct {
+	info          BuildInfo
+	client        *client.Client
+	pluginManager *plugins.PluginManager
+	startTime     time.Time
+
+	mux      *http.ServeMux
+	server   *http.Server
+	listener net.Listener
+}
+
+func NewServer(info BuildInfo, cli *client.Client, pm *plugins.PluginManager) *Server {
+	s := &Server{
+		info:          info,
+		client:        cli,
+		pluginManager: pm,
+		startTime:     time.Now(),
+		mux:           http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/readyz", s.handleReadyz)
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.Handle("/metrics", metrics.Handler())
+	return s
+}
+
+// Handler 返回管理接口的 http.Handler
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start 监听 address 并在后台处理请求，监听失败时返回错误
+func (s *Server) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	if !isLoopback(address) {
+		logger.Log1.WithField("address", address).Warn("管理接口监听在非本机地址，能访问该地址的任何人都可以查看运行状态")
+	}
+	s.listener = listener
+	s.server = &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Log1.Errorf("管理接口异常退出: %v", err)
+		}
+	}()
+	logger.Log1.WithField("address", listener.Addr().String()).Info("管理接口已启动")
+	return nil
+}
+
+// Addr 返回实际监听的地址，未启动时为空
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close 关闭管理接口
+func (s *Server) Close() error {
+	if s.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// Status 返回当前的运行状态
+func (s *Server) Status() Status {
+	uptime := time.Since(s.startTime)
+	connection := s.client.Status()
+	return Status{
+		BuildInfo:     s.info,
+		GoVersion:     runtime.Version(),
+		StartTime:     s.startTime,
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		Ready:         connection.Connected && s.pluginManager.Loaded(),
+		Connection:    connection,
+		Plugins:       s.pluginManager.Status(),
+	}
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	var reasons []string
+	if !s.pluginManager.Loaded() {
+		reasons = append(reasons, "插件未加载")
+	}
+	if !s.client.Status().Connected {
+		reasons = append(reasons, "未连接到服务器")
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(reasons) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, reason := range reasons {
+			w.Write([]byte(reason + "\n"))
+		}
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(s.Status())
+}
+
+// isLoopback 地址是否只监听本机，主机名只认 localhost
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/client"
+	"github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+)
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	body, err := io.ReadAll(recorder.Result().Body)
+	require.NoError(t, err)
+	return recorder.Code, string(body)
+}
+
+func TestServer(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("plugins.grpc", "greeter")
+
+	pm := plugins.NewPluginManager()
+	cli := client.NewClient(nil, pm)
+	s := NewServer(BuildInfo{Version: "1.2.3", GitCommit: "abc"}, cli, pm)
+	handler := s.Handler()
+
+	code, body := get(t, handler, "/healthz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ok\n", body)
+
+	code, body = get(t, handler, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "插件未加载\n未连接到服务器\n", body)
+
+	require.NoError(t, pm.LoadPlugins())
+	code, body = get(t, handler, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "未连接到服务器\n", body)
+
+	code, body = get(t, handler, "/status")
+	require.Equal(t, http.StatusOK, code)
+	var status Status
+	require.NoError(t, json.Unmarshal([]byte(body), &status))
+	require.Equal(t, "1.2.3", status.Version)
+	require.Equal(t, "abc", status.GitCommit)
+	require.False(t, status.Ready)
+	require.False(t, status.Connection.Connected)
+
+	// 格式错误的 gRPC 配置初始化失败
+	grpc := status.Plugins["grpc_plugin"]
+	require.False(t, grpc.Initialized)
+	require.Contains(t, grpc.LastError, "解析 gRPC 配置出错")
+	require.NotNil(t, grpc.LastErrorAt)
+	require.True(t, status.Plugins["http_plugin"].Initialized)
+
+	code, body = get(t, handler, "/metrics")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, "go_goroutines")
+}
+
+func TestServer_Start(t *testing.T) {
+	pm := plugins.NewPluginManager()
+	s := NewServer(BuildInfo{}, client.NewClient(nil, pm), pm)
+	require.NoError(t, s.Start("127.0.0.1:0"))
+	defer s.Close()
+
+	resp, err := http.Get("http://" + s.Addr() + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// 端口已被占用时返回错误
+	require.Error(t, NewServer(BuildInfo{}, nil, pm).Start(s.Addr()))
+
+	require.True(t, isLoopback("127.0.0.1:8089"))
+	require.True(t, isLoopback("localhost:8089"))
+	require.True(t, isLoopback("[::1]:8089"))
+	require.False(t, isLoopback("0.0.0.0:8089"))
+	require.False(t, isLoopback(":8089"))
+}
@@ -0,0 +1,318 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// hmacField 追加在每行末尾的签名字段，签名覆盖该字段之前的全部内容
+const hmacField = `,"hmac":"`
+
+// Entry 审计日志中的一条记录，每条回调消息对应一行 JSON
+type Entry struct {
+	Time            string `json:"time"`
+	MessageID       string `json:"messageId"`
+	ConnectorCorpID string `json:"connectorCorpId,omitempty"`
+	ConnectorID     string `json:"connectorId,omitempty"`
+	ActionID        string `json:"actionId,omitempty"`
+	Plugin          string `json:"plugin"`
+	ConfigKey       string `json:"configKey,omitempty"`
+	// 操作类型: sql、http、grpc
+	Operation string `json:"operation,omitempty"`
+	// 字面量替换为 ? 之后的 SQL，以及原始 SQL 的 sha256
+	SQL     string `json:"sql,omitempty"`
+	SQLHash string `json:"sqlHash,omitempty"`
+	// HTTP 请求的方法和地址，地址不包含查询参数
+	Method string `json:"method,omitempty"`
+	URL    string `json:"url,omitempty"`
+	// gRPC 调用的方法，例如 /helloworld.Greeter/SayHello
+	Command string `json:"command,omitempty"`
+	// success 或 error
+	Status string `json:"status"`
+	// HTTP 状态码或 gRPC 状态码
+	Code       *int   `json:"code,omitempty"`
+	Rows       *int   `json:"rows,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+	// 开启 HMAC 时为上一条记录的签名，用于发现删除或调换记录
+	Prev string `json:"prev,omitempty"`
+}
+
+// Options 审计日志文件和轮转配置
+type Options struct {
+	File string
+	// 单个文件的最大大小，单位 MB
+	MaxSize int
+	// 保留的旧文件个数，0 表示全部保留
+	MaxBackups int
+	// 旧文件保留天数，0 表示不按时间删除
+	MaxAge   int
+	Compress bool
+	// 不为空时对每条记录签名，并与上一条记录的签名链接
+	HMACKey []byte
+}
+
+// Logger 只追加写入的审计日志
+type Logger struct {
+	mu   sync.Mutex
+	out  io.WriteCloser
+	key  []byte
+	prev string
+}
+
+// Open 打开审计日志，文件按大小轮转，新文件的权限为 0600；
+// 开启 HMAC 时从已有文件的最后一条完整记录继续签名链
+func Open(opts Options) (*Logger, error) {
+	l := &Logger{
+		out: &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSize,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAge,
+			Compress:   opts.Compress,
+		},
+		key: opts.HMACKey,
+	}
+	if err := terminateLine(opts.File); err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %w", err)
+	}
+	if len(l.key) > 0 {
+		prev, err := lastSignature(opts.File)
+		if err != nil {
+			return nil, fmt.Errorf("读取审计日志失败: %w", err)
+		}
+		l.prev = prev
+	}
+	return l, nil
+}
+
+// Write 写入一条记录
+func (l *Logger) Write(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.key) > 0 {
+		entry.Prev = l.prev
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if len(l.key) > 0 {
+		signature := sign(l.key, line)
+		line = append(line[:len(line)-1], hmacField+signature+`"}`...)
+		l.prev = signature
+	}
+	_, err = l.out.Write(append(line, '\n'))
+	return err
+}
+
+// Close 关闭审计日志
+func (l *Logger) Close() error {
+	return l.out.Close()
+}
+
+func sign(key, line []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(line)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// splitSignature 拆分出签名覆盖的内容和签名，没有签名时返回 false
+func splitSignature(line []byte) ([]byte, string, bool) {
+	i := bytes.LastIndex(line, []byte(hmacField))
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, "", false
+	}
+	signature := string(line[i+len(hmacField) : len(line)-2])
+	return append(line[:i:i], '}'), signature, true
+}
+
+// terminateLine 文件末尾是崩溃时写了一半的记录时补上换行，避免与之后的记录拼在同一行
+func terminateLine(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.WriteAt([]byte{'\n'}, info.Size())
+	return err
+}
+
+// lastSignature 返回最后一条完整记录的签名，跳过末尾不完整的行；
+// 当前文件不存在或没有完整的记录时 (例如刚轮转)，从最新的轮转文件中读取。
+// 都没有签名时为空
+func lastSignature(path string) (string, error) {
+	signature, err := fileSignature(path)
+	if err != nil || signature != "" {
+		return signature, err
+	}
+	backups, err := Backups(path)
+	if err != nil || len(backups) == 0 {
+		return "", err
+	}
+	return fileSignature(backups[len(backups)-1])
+}
+
+// fileSignature 返回文件中最后一条完整记录的签名，文件不存在时为空
+func fileSignature(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if errors.Is(err, io.EOF) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		defer gz.Close()
+		return lastSignedLine(gz)
+	}
+
+	// 单条记录不会很长，只读取文件末尾
+	const tail = 64 * 1024
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := max(info.Size()-tail, 0)
+	return lastSignedLine(io.NewSectionReader(f, offset, info.Size()-offset))
+}
+
+// lastSignedLine 返回最后一条以换行结尾且带签名的记录的签名
+func lastSignedLine(r io.Reader) (string, error) {
+	br := bufio.NewReader(r)
+	var signature string
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// 没有换行结尾的是写了一半的记录
+			return signature, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, s, ok := splitSignature(bytes.TrimSuffix(line, []byte("\n"))); ok {
+			signature = s
+		}
+	}
+}
+
+// Backups 返回轮转后的旧文件 (<name>-<时间><ext>[.gz])，按写入顺序排列，不包含当前文件
+func Backups(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, m := range matches {
+		if strings.HasSuffix(m, ext) || strings.HasSuffix(m, ext+".gz") {
+			files = append(files, m)
+		}
+	}
+	// 文件名中的时间格式固定，按名称排序即按时间排序
+	sort.Strings(files)
+	return files, nil
+}
+
+// Verifier 按顺序校验记录的签名和签名链，可以依次校验轮转后的多个文件
+type Verifier struct {
+	key  []byte
+	prev string
+	// 已校验的记录数
+	Count int
+}
+
+func NewVerifier(key []byte) *Verifier {
+	return &Verifier{key: key}
+}
+
+// Verify 校验 r 中的全部记录，第一条记录的 prev 只在之前已校验过记录时检查
+func (v *Verifier) Verify(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		content, signature, ok := splitSignature(line)
+		if !ok {
+			return fmt.Errorf("第 %d 行缺少签名", lineNo)
+		}
+		if !hmac.Equal([]byte(sign(v.key, content)), []byte(signature)) {
+			return fmt.Errorf("第 %d 行签名不匹配，记录被修改或密钥错误", lineNo)
+		}
+		var entry Entry
+		if err := json.Unmarshal(content, &entry); err != nil {
+			return fmt.Errorf("第 %d 行格式错误: %w", lineNo, err)
+		}
+		if v.Count > 0 && entry.Prev != v.prev {
+			return fmt.Errorf("第 %d 行与上一条记录不连续，之前的记录被删除或调换", lineNo)
+		}
+		v.prev = signature
+		v.Count++
+	}
+	return scanner.Err()
+}
+
+var defaultLogger atomic.Pointer[Logger]
+
+// Setup 打开审计日志，之后处理的消息都会写入该文件
+func Setup(opts Options) error {
+	l, err := Open(opts)
+	if err != nil {
+		return err
+	}
+	if old := defaultLogger.Swap(l); old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Close 关闭审计日志，之后不再记录
+func Close() error {
+	if l := defaultLogger.Swap(nil); l != nil {
+		return l.Close()
+	}
+	return nil
+}
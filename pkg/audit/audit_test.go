@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+func readEntries(t *testing.T, path string) []Entry {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var entries []Entry
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var entry Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogger_HMACChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(Options{File: path, MaxSize: 1, HMACKey: testKey})
+	require.NoError(t, err)
+	require.NoError(t, l.Write(Entry{MessageID: "msg-1", Plugin: "mysql_plugin", Status: "success"}))
+	require.NoError(t, l.Write(Entry{MessageID: "msg-2", Plugin: "mysql_plugin", Status: "error"}))
+	require.NoError(t, l.Close())
+
+	// 重新打开后从最后一条记录继续签名链
+	l, err = Open(Options{File: path, MaxSize: 1, HMACKey: testKey})
+	require.NoError(t, err)
+	require.NoError(t, l.Write(Entry{MessageID: "msg-3", Plugin: "http_plugin", Status: "success"}))
+	require.NoError(t, l.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	entries := readEntries(t, path)
+	require.Len(t, entries, 3)
+	require.Empty(t, entries[0].Prev)
+	require.NotEmpty(t, entries[2].Prev)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	verifier := NewVerifier(testKey)
+	require.NoError(t, verifier.Verify(bytes.NewReader(content)))
+	require.Equal(t, 3, verifier.Count)
+
+	lines := strings.SplitAfter(string(content), "\n")
+
+	// 修改记录
+	tampered := strings.Replace(string(content), `"status":"error"`, `"status":"success"`, 1)
+	require.ErrorContains(t, NewVerifier(testKey).Verify(strings.NewReader(tampered)), "第 2 行签名不匹配")
+
+	// 删除中间的记录
+	deleted := lines[0] + lines[2]
+	require.ErrorContains(t, NewVerifier(testKey).Verify(strings.NewReader(deleted)), "第 2 行与上一条记录不连续")
+
+	// 密钥错误
+	require.Error(t, NewVerifier([]byte("fedcba9876543210")).Verify(bytes.NewReader(content)))
+
+	// 轮转后的文件依次校验，签名链在文件之间连续
+	verifier = NewVerifier(testKey)
+	require.NoError(t, verifier.Verify(strings.NewReader(lines[0]+lines[1])))
+	require.NoError(t, verifier.Verify(strings.NewReader(lines[2])))
+	require.Error(t, NewVerifier(testKey).Verify(strings.NewReader(lines[0]+lines[2])))
+}
+
+func TestLogger_ResumeChain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	l, err := Open(Options{File: path, HMACKey: testKey})
+	require.NoError(t, err)
+	require.NoError(t, l.Write(Entry{MessageID: "msg-1", Status: "success"}))
+	require.NoError(t, l.Write(Entry{MessageID: "msg-2", Status: "success"}))
+	require.NoError(t, l.Close())
+	complete, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// 崩溃时写了一半的记录不影响签名链
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"time":"","messageId":"msg-x"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	l, err = Open(Options{File: path, HMACKey: testKey})
+	require.NoError(t, err)
+	require.NoError(t, l.Write(Entry{MessageID: "msg-3", Status: "success"}))
+	require.NoError(t, l.Close())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(content), "\n")
+	require.Len(t, lines, 5)
+	require.Equal(t, `{"time":"","messageId":"msg-x"`+"\n", lines[2])
+	verifier := NewVerifier(testKey)
+	require.NoError(t, verifier.Verify(strings.NewReader(lines[0]+lines[1]+lines[3])))
+	require.Equal(t, 3, verifier.Count)
+
+	// 轮转后当前文件为空时从最新的轮转文件继续
+	backup := filepath.Join(dir, "audit-2026-01-01T00-00-00.000.log.gz")
+	out, err := os.Create(backup)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(out)
+	_, err = gz.Write(complete)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, out.Close())
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	l, err = Open(Options{File: path, HMACKey: testKey})
+	require.NoError(t, err)
+	require.NoError(t, l.Write(Entry{MessageID: "msg-3", Status: "success"}))
+	require.NoError(t, l.Close())
+	rotated, err := os.ReadFile(path)
+	require.NoError(t, err)
+	verifier = NewVerifier(testKey)
+	require.NoError(t, verifier.Verify(bytes.NewReader(complete)))
+	require.NoError(t, verifier.Verify(bytes.NewReader(rotated)))
+	require.Equal(t, 3, verifier.Count)
+}
+
+func TestLogger_WithoutHMAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(Options{File: path})
+	require.NoError(t, err)
+	require.NoError(t, l.Write(Entry{MessageID: "msg-1", Status: "success"}))
+	require.NoError(t, l.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(content), "hmac")
+	require.NotContains(t, string(content), "prev")
+	require.ErrorContains(t, NewVerifier(testKey).Verify(bytes.NewReader(content)), "缺少签名")
+}
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, Setup(Options{File: path}))
+	defer Close()
+
+	record := NewRecord("msg-1", "mysql_plugin")
+	record.SetConnector("corp", "connector", "action")
+	ctx := WithRecord(context.Background(), record)
+	FromContext(ctx).SetSQL("orders", "SELECT * FROM orders WHERE phone = '13800000000'")
+	FromContext(ctx).SetRows(0)
+	record.Finish(nil)
+
+	// 没有 Record 时不做处理
+	FromContext(context.Background()).SetHTTP("erp", "GET", "https://erp.example.com/api")
+
+	record = NewRecord("msg-2", "http_plugin")
+	record.SetHTTP("", "POST", "https://erp.example.com/api")
+	record.SetCode(502)
+	record.Finish(errors.New("bad gateway"))
+
+	entries := readEntries(t, path)
+	require.Len(t, entries, 2)
+	require.Equal(t, "msg-1", entries[0].MessageID)
+	require.Equal(t, "corp", entries[0].ConnectorCorpID)
+	require.Equal(t, "connector", entries[0].ConnectorID)
+	require.Equal(t, "action", entries[0].ActionID)
+	require.Equal(t, "orders", entries[0].ConfigKey)
+	require.Equal(t, "sql", entries[0].Operation)
+	require.Equal(t, "SELECT * FROM orders WHERE phone = ?", entries[0].SQL)
+	require.Equal(t, HashSQL("SELECT * FROM orders WHERE phone = '13800000000'"), entries[0].SQLHash)
+	require.Equal(t, 0, *entries[0].Rows)
+	require.Equal(t, "success", entries[0].Status)
+	require.NotEmpty(t, entries[0].Time)
+
+	require.Equal(t, "http", entries[1].Operation)
+	require.Equal(t, "POST", entries[1].Method)
+	require.Equal(t, 502, *entries[1].Code)
+	require.Nil(t, entries[1].Rows)
+	require.Equal(t, "error", entries[1].Status)
+	require.Equal(t, "bad gateway", entries[1].Error)
+}
+
+func TestRedactSQL(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM t WHERE a = 'x' AND b = 12":         "SELECT * FROM t WHERE a = ? AND b = ?",
+		"SELECT * FROM t WHERE a = 'it''s' OR a = 'a\\'b'": "SELECT * FROM t WHERE a = ? OR a = ?",
+		"INSERT INTO t2 (c1) VALUES (N'张三', -1.5e3, 0xFF)": "INSERT INTO t2 (c1) VALUES (?, -?, ?)",
+		"SELECT `col1`, \"col 2\", [col3] FROM t":          "SELECT `col1`, \"col 2\", [col3] FROM t",
+		"SELECT * FROM t WHERE id = :1 OR id = $2":         "SELECT * FROM t WHERE id = :1 OR id = $2",
+		"SELECT name FROM t WHERE note = 'unterminated":    "SELECT name FROM t WHERE note = ?",
+	}
+	for query, expected := range cases {
+		require.Equal(t, expected, RedactSQL(query), query)
+	}
+}
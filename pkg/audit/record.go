@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+)
+
+type recordKey struct{}
+
+// Record 处理一条消息期间收集的审计信息，插件通过 FromContext 获取并补充操作内容；
+// 所有方法都可以在 nil 上调用
+type Record struct {
+	mu        sync.Mutex
+	entry     Entry
+	startTime time.Time
+}
+
+// NewRecord 开始记录一条消息
+func NewRecord(messageID, plugin string) *Record {
+	return &Record{
+		entry: Entry{
+			MessageID: messageID,
+			Plugin:    plugin,
+		},
+		startTime: time.Now(),
+	}
+}
+
+// WithRecord 将 r 放入 ctx，插件执行 SQL、发起请求时写入操作内容
+func WithRecord(ctx context.Context, r *Record) context.Context {
+	return context.WithValue(ctx, recordKey{}, r)
+}
+
+// FromContext 返回 ctx 中的 Record，没有时为 nil
+func FromContext(ctx context.Context) *Record {
+	r, _ := ctx.Value(recordKey{}).(*Record)
+	return r
+}
+
+// SetConnector 记录平台传入的连接器信息
+func (r *Record) SetConnector(corpID, connectorID, actionID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.ConnectorCorpID = corpID
+	r.entry.ConnectorID = connectorID
+	r.entry.ActionID = actionID
+}
+
+// SetSQL 记录执行的 SQL，字面量会被替换
+func (r *Record) SetSQL(configKey, query string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.ConfigKey = configKey
+	r.entry.Operation = "sql"
+	r.entry.SQL = RedactSQL(query)
+	r.entry.SQLHash = HashSQL(query)
+}
+
+// SetRows 记录 SQL 返回的行数
+func (r *Record) SetRows(rows int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.Rows = &rows
+}
+
+// SetHTTP 记录 HTTP 请求，url 不应包含查询参数
+func (r *Record) SetHTTP(configKey, method, url string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.ConfigKey = configKey
+	r.entry.Operation = "http"
+	r.entry.Method = method
+	r.entry.URL = url
+}
+
+// SetCommand 记录 gRPC 调用的方法
+func (r *Record) SetCommand(configKey, command string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.ConfigKey = configKey
+	r.entry.Operation = "grpc"
+	r.entry.Command = command
+}
+
+// SetCode 记录 HTTP 或 gRPC 的状态码
+func (r *Record) SetCode(code int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.Code = &code
+}
+
+// Finish 记录处理结果并写入审计日志，未开启审计日志时不做处理
+func (r *Record) Finish(err error) {
+	if r == nil {
+		return
+	}
+	l := defaultLogger.Load()
+	if l == nil {
+		return
+	}
+	r.mu.Lock()
+	entry := r.entry
+	r.mu.Unlock()
+	entry.Time = r.startTime.Format(time.RFC3339Nano)
+	entry.DurationMs = time.Since(r.startTime).Milliseconds()
+	entry.Status = "success"
+	if err != nil {
+		entry.Status = "error"
+		entry.Error = logger.RedactString(err.Error())
+	}
+	if err := l.Write(entry); err != nil {
+		logger.Log1.Errorf("写入审计日志失败: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RedactSQL 将 SQL 中的字符串和数字字面量替换为 ?，保留语句结构，
+// 双引号、反引号和方括号按标识符处理，不做替换
+func RedactSQL(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			// N'...'、E'...'、X'...' 等带前缀的字面量连同前缀一起替换
+			if n := b.Len(); n > 0 && isPrefix(b.String()) {
+				s := b.String()[:n-1]
+				b.Reset()
+				b.WriteString(s)
+			}
+			i = skipQuoted(query, i)
+			b.WriteByte('?')
+		case c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				i = len(query)
+				continue
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 2
+		case c == '[':
+			end := strings.IndexByte(query[i:], ']')
+			if end < 0 {
+				end = len(query) - i - 1
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end + 1
+		// :1 等占位符不是字面量
+		case isDigit(c) && (i == 0 || !isIdent(query[i-1]) && query[i-1] != ':'):
+			i = skipNumber(query, i)
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// HashSQL 原始 SQL 的 sha256，可以与数据库的日志对照
+func HashSQL(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// skipQuoted 返回单引号字符串之后的位置，支持 ” 和 \' 转义
+func skipQuoted(query string, i int) int {
+	for i++; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case '\'':
+			if i+1 < len(query) && query[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// skipNumber 返回数字字面量之后的位置，包括小数、科学计数法和 0x 开头的十六进制
+func skipNumber(query string, i int) int {
+	if strings.HasPrefix(query[i:], "0x") || strings.HasPrefix(query[i:], "0X") {
+		i += 2
+		for i < len(query) && isHex(query[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+		i++
+	}
+	if i < len(query) && (query[i] == 'e' || query[i] == 'E') {
+		j := i + 1
+		if j < len(query) && (query[j] == '+' || query[j] == '-') {
+			j++
+		}
+		if j < len(query) && isDigit(query[j]) {
+			for i = j; i < len(query) && isDigit(query[i]); i++ {
+			}
+		}
+	}
+	return i
+}
+
+// isPrefix 已写入的内容是否以单独的字符串前缀结尾，例如 N'...' 中的 N
+func isPrefix(s string) bool {
+	n := len(s)
+	switch s[n-1] {
+	case 'N', 'n', 'E', 'e', 'X', 'x', 'B', 'b':
+		return n == 1 || !isIdent(s[n-2])
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isIdent(c byte) bool {
+	return isDigit(c) || c == '_' || c == '$' || c == '@' || c == '#' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
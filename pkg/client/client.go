@@ -2,21 +2,78 @@ package client
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/open-dingtalk/ipaas-agent/pkg/config"
 	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
 	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
 	"github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	pluginsv1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/tracing"
 
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/client"
 	sdkLogger "github.com/open-dingtalk/dingtalk-stream-sdk-go/logger"
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/utils"
 )
 
+const callbackPath = "/v1.0/ipaas/proxy/callback"
+
+// 关闭连接前等待正在处理的回调完成的最长时间
+const drainTimeout = 30 * time.Second
+
+// 重新连接失败后的重试间隔
+const redialInterval = 3 * time.Second
+
+// 超过该时间未收到服务器消息 (服务器会定期发送 ping) 时认为连接已断开并重新连接
+var idleTimeout = 2 * time.Minute
+
+// SDK 的日志是全局的，只需设置一次
+var setSDKLogger sync.Once
+
+// stream 一条到服务器的连接及其上正在处理的回调数
+type stream struct {
+	auth     v1.AuthClientConfig
+	client   *client.StreamClient
+	inflight atomic.Int64
+
+	// 收到服务器的 disconnect 消息、连接空闲超时或主动关闭后为 false，
+	// 重新连接后收到 ping 或回调时恢复为 true
+	connected     atomic.Bool
+	connectedAt   atomic.Int64
+	lastMessageAt atomic.Int64
+	// 最近一次建立 websocket 连接的时间，用于判断空闲超时
+	dialedAt atomic.Int64
+
+	// 重连由 redial 负责，SDK 的自动重连已关闭；
+	// closing 置位后 redial 不再重连，done 用于打断重试等待
+	closing   atomic.Bool
+	redialing atomic.Bool
+	done      chan struct{}
+}
+
 type Client struct {
 	conf          *v1.AuthClientConfig
 	pluginManager *plugins.PluginManager
-	streamClient  *client.StreamClient
+
+	mu     sync.Mutex
+	ctx    context.Context
+	stream *stream
+
+	// 建立首个连接之后重新连接的次数
+	reconnects atomic.Int64
+}
+
+// ConnectionStatus 与服务器连接的状态
+type ConnectionStatus struct {
+	Connected     bool       `json:"connected"`
+	ClientID      string     `json:"clientID,omitempty"`
+	ConnectedAt   *time.Time `json:"connectedAt,omitempty"`
+	LastMessageAt *time.Time `json:"lastMessageAt,omitempty"`
+	Reconnects    int64      `json:"reconnects"`
 }
 
 func NewClient(conf *v1.AuthClientConfig, pm *plugins.PluginManager) *Client {
@@ -29,39 +86,256 @@ func NewClient(conf *v1.AuthClientConfig, pm *plugins.PluginManager) *Client {
 func (c *Client) Connect(ctx context.Context) error {
 	// 初始化与服务器的连接
 	// 注册日志Logger
-	sdkLogger.SetLogger(logger.Log2)
+	setSDKLogger.Do(func() {
+		sdkLogger.SetLogger(logger.Log2)
+	})
 	auth := config.GetAuthClientConfig()
-	c.streamClient = client.NewStreamClient(
-		client.WithAppCredential(client.NewAppCredentialConfig(auth.ClientID, auth.ClientSecret)),
-		client.WithOpenApiHost(auth.OpenAPIHost),
-		client.WithExtras(map[string]string{}),
-	)
 
-	// 注册事件类型的处理函数 callback 是 goroutin
-	c.streamClient.RegisterCallbackRouter("/v1.0/ipaas/proxy/callback", c.handleServerMessage)
-	err := c.streamClient.Start(ctx)
+	s, err := c.openStream(ctx, auth)
 	if err != nil {
 		logger.Log1.Errorf("连接到服务器失败: %v", err)
 		return err
 	}
+	c.mu.Lock()
+	c.ctx = ctx
+	c.conf = auth
+	c.stream = s
+	c.mu.Unlock()
 	logger.Log1.Info("成功连接到服务器")
 	return nil
 }
 
-func (c *Client) handleServerMessage(ctx context.Context, df *payload.DataFrame) (*payload.DataFrameResponse, error) {
+// Reconnect 使用新的凭证建立连接，成功后将消息切换到新连接，再关闭旧连接；
+// 关闭前等待旧连接上正在处理的回调完成。新连接建立失败时继续使用旧连接。
+// 建立连接时不持有锁，Status 不会被阻塞
+func (c *Client) Reconnect(auth *v1.AuthClientConfig) error {
+	c.mu.Lock()
+	old := c.stream
+	if old != nil && old.auth == *auth {
+		c.mu.Unlock()
+		return nil
+	}
+	ctx := c.ctx
+	c.mu.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	s, err := c.openStream(ctx, auth)
+	if err != nil {
+		logger.Log1.WithField("clientID", auth.ClientID).Errorf("使用新凭证连接服务器失败，继续使用当前连接: %v", err)
+		return err
+	}
+	c.mu.Lock()
+	if c.stream != old {
+		// 建立连接期间已切换到其它连接或已断开，放弃新连接
+		c.mu.Unlock()
+		s.close()
+		logger.Log1.WithField("clientID", auth.ClientID).Warn("连接已被其它操作替换，关闭新建立的连接")
+		return nil
+	}
+	c.conf = auth
+	c.stream = s
+	c.mu.Unlock()
+	c.reconnects.Add(1)
+	metrics.Reconnects.WithLabelValues("credentials").Inc()
+
+	logger.Log1.WithField("clientID", auth.ClientID).Info("已使用新凭证连接到服务器")
+	if old != nil {
+		old.close()
+		logger.Log1.WithField("clientID", old.auth.ClientID).Info("旧连接已关闭")
+	}
+	return nil
+}
+
+func (c *Client) openStream(ctx context.Context, auth *v1.AuthClientConfig) (*stream, error) {
+	s := &stream{auth: *auth, done: make(chan struct{})}
+	s.client = client.NewStreamClient(
+		client.WithAppCredential(client.NewAppCredentialConfig(auth.ClientID, auth.ClientSecret)),
+		client.WithOpenApiHost(auth.OpenAPIHost),
+		client.WithExtras(map[string]string{}),
+		// SDK 的自动重连无法在关闭连接时停止，由 redial 负责重连
+		client.WithAutoReconnect(false),
+	)
+
+	// 注册事件类型的处理函数 callback 是 goroutin
+	s.client.RegisterCallbackRouter(callbackPath, func(ctx context.Context, df *payload.DataFrame) (*payload.DataFrameResponse, error) {
+		s.inflight.Add(1)
+		defer s.inflight.Add(-1)
+		c.touch(s)
+		return c.handleServerMessage(ctx, df)
+	})
+	// 替换 SDK 默认的系统消息处理函数以记录连接状态
+	s.client.RegisterRouter(utils.SubscriptionTypeKSystem, "ping", func(ctx context.Context, df *payload.DataFrame) (*payload.DataFrameResponse, error) {
+		c.touch(s)
+		return s.client.OnPing(ctx, df)
+	})
+	s.client.RegisterRouter(utils.SubscriptionTypeKSystem, "disconnect", func(ctx context.Context, df *payload.DataFrame) (*payload.DataFrameResponse, error) {
+		s.connected.Store(false)
+		logger.Log1.WithField("clientID", s.auth.ClientID).Warn("服务器要求断开连接，等待自动重连")
+		response, err := s.client.OnDisconnect(ctx, df)
+		go s.redial()
+		return response, err
+	})
+	if err := s.client.Start(ctx); err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixNano()
+	s.connectedAt.Store(now)
+	s.lastMessageAt.Store(now)
+	s.dialedAt.Store(now)
+	s.connected.Store(true)
+	go s.watch()
+	return s, nil
+}
+
+// redial 关闭当前连接并重新连接，直到成功或连接被主动关闭
+func (s *stream) redial() {
+	if !s.redialing.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.redialing.Store(false)
+
+	s.client.Close()
+	for !s.closing.Load() {
+		err := s.client.Start(context.Background())
+		if err == nil {
+			s.dialedAt.Store(time.Now().UnixNano())
+			// 重连期间连接被主动关闭时，关闭刚建立的连接
+			if s.closing.Load() {
+				s.client.Close()
+			}
+			return
+		}
+		logger.Log1.WithField("clientID", s.auth.ClientID).Errorf("重新连接服务器失败: %v", err)
+		select {
+		case <-s.done:
+			return
+		case <-time.After(redialInterval):
+		}
+	}
+}
+
+// watch 在连接长时间未收到服务器消息时重新连接，
+// SDK 关闭自动重连后读取失败的连接只能由此发现
+func (s *stream) watch() {
+	ticker := time.NewTicker(idleTimeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		last := max(s.lastMessageAt.Load(), s.dialedAt.Load())
+		if s.redialing.Load() || time.Since(time.Unix(0, last)) < idleTimeout {
+			continue
+		}
+		s.connected.Store(false)
+		logger.Log1.WithField("clientID", s.auth.ClientID).Warnf("超过 %s 未收到服务器消息，重新连接", idleTimeout)
+		s.redial()
+	}
+}
+
+// touch 记录收到服务器消息，断开后再次收到消息说明已重新连接
+func (c *Client) touch(s *stream) {
+	now := time.Now().UnixNano()
+	s.lastMessageAt.Store(now)
+	if !s.closing.Load() && s.connected.CompareAndSwap(false, true) {
+		s.connectedAt.Store(now)
+		c.reconnects.Add(1)
+		metrics.Reconnects.WithLabelValues("server").Inc()
+		logger.Log1.WithField("clientID", s.auth.ClientID).Info("已重新连接到服务器")
+	}
+}
+
+// Status 返回当前连接的状态
+func (c *Client) Status() ConnectionStatus {
+	c.mu.Lock()
+	s := c.stream
+	c.mu.Unlock()
+	status := ConnectionStatus{Reconnects: c.reconnects.Load()}
+	if s == nil {
+		return status
+	}
+	status.Connected = s.connected.Load()
+	status.ClientID = s.auth.ClientID
+	status.ConnectedAt = unixTime(s.connectedAt.Load())
+	status.LastMessageAt = unixTime(s.lastMessageAt.Load())
+	return status
+}
+
+func unixTime(nano int64) *time.Time {
+	if nano == 0 {
+		return nil
+	}
+	t := time.Unix(0, nano)
+	return &t
+}
+
+// CheckCredentials 使用凭证向 openAPIHost 申请连接地址，检查凭证是否有效，不建立连接
+func CheckCredentials(ctx context.Context, auth *v1.AuthClientConfig) error {
+	cli := client.NewStreamClient(
+		client.WithAppCredential(client.NewAppCredentialConfig(auth.ClientID, auth.ClientSecret)),
+		client.WithOpenApiHost(auth.OpenAPIHost),
+		client.WithExtras(map[string]string{}),
+	)
+	cli.RegisterCallbackRouter(callbackPath, func(ctx context.Context, df *payload.DataFrame) (*payload.DataFrameResponse, error) {
+		return nil, nil
+	})
+	_, err := cli.GetConnectionEndpoint(ctx)
+	return err
+}
+
+// close 等待正在处理的回调完成 (最多 drainTimeout) 后关闭连接
+func (s *stream) close() {
+	// 主动关闭的连接不再重连
+	s.closing.Store(true)
+	close(s.done)
+	s.connected.Store(false)
+	deadline := time.Now().Add(drainTimeout)
+	for s.inflight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := s.inflight.Load(); n > 0 {
+		logger.Log1.WithField("clientID", s.auth.ClientID).Warnf("仍有 %d 个回调未处理完成，强制关闭连接", n)
+	}
+	s.client.Close()
+}
+
+func (c *Client) handleServerMessage(ctx context.Context, df *payload.DataFrame) (response *payload.DataFrameResponse, err error) {
+	// 以消息头中的 trace 上下文或消息 id 作为父级
+	ctx, span := tracing.StartCallback(ctx, "Client.handleServerMessage", df.Headers, df.GetMessageId())
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	// 根据消息类型选择插件处理
-	logger.Log1.Infof("收到服务器消息: %v", df)
-	response, err := c.pluginManager.HandleMessage(ctx, df)
+	entry := logger.Log1.WithField("messageId", df.GetMessageId())
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		entry = entry.WithField("traceId", traceID)
+	}
+	// 消息内容可能包含 SQL、请求体和密码，只在 debug 级别以字段输出，字段名按规则脱敏
+	dfWrap := &pluginsv1.DFWrap{DataFrame: df}
+	entry.WithField("plugin", dfWrap.GetPluginName()).
+		WithField("dataVersion", dfWrap.GetDataVersion()).
+		Info("收到服务器消息")
+	entry.WithField("data", dfWrap.GetDataJson()).Debug("服务器消息内容")
+	response, err = c.pluginManager.HandleMessage(ctx, df)
 	if err != nil {
-		logger.Log1.WithField("错误", err).Errorf("处理消息失败")
+		entry.WithField("错误", err).Errorf("处理消息失败")
 		return nil, err
 	}
 	return response, nil
 }
 
 func (c *Client) Disconnect() {
-	if c.streamClient != nil {
-		c.streamClient.Close()
+	c.mu.Lock()
+	s := c.stream
+	c.stream = nil
+	c.mu.Unlock()
+	if s != nil {
+		s.close()
 	}
 	logger.Log1.Info("已断开与服务器的连接")
 	c.pluginManager.CloseAll()
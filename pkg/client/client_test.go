@@ -0,0 +1,379 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	pluginsv1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+)
+
+// fakeGateway 模拟钉钉 Stream 网关，ticket 即 clientId
+type fakeGateway struct {
+	*httptest.Server
+	mu    sync.Mutex
+	opens map[string]int
+	conns chan gatewayConn
+	// 不为空时申请连接地址的请求等待其关闭后返回
+	hold chan struct{}
+}
+
+type gatewayConn struct {
+	clientID string
+	conn     *websocket.Conn
+}
+
+func newFakeGateway(t *testing.T) *fakeGateway {
+	g := &fakeGateway{opens: make(map[string]int), conns: make(chan gatewayConn, 4)}
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1.0/gateway/connections/open", func(w http.ResponseWriter, r *http.Request) {
+		var req payload.ConnectionEndpointRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		g.mu.Lock()
+		g.opens[req.ClientId]++
+		hold := g.hold
+		g.mu.Unlock()
+		if hold != nil {
+			<-hold
+		}
+		json.NewEncoder(w).Encode(payload.ConnectionEndpointResponse{
+			Endpoint: "ws" + strings.TrimPrefix(g.URL, "http") + "/connect",
+			Ticket:   req.ClientId,
+		})
+	})
+	mux.HandleFunc("/connect", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		g.conns <- gatewayConn{clientID: r.URL.Query().Get("ticket"), conn: conn}
+	})
+	g.Server = httptest.NewServer(mux)
+	return g
+}
+
+func (g *fakeGateway) openCount(clientID string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.opens[clientID]
+}
+
+func (g *fakeGateway) accept(t *testing.T) gatewayConn {
+	select {
+	case c := <-g.conns:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待连接超时")
+		return gatewayConn{}
+	}
+}
+
+// blockingPlugin 收到消息后等待 release 再返回
+type blockingPlugin struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingPlugin) Init() error { return nil }
+
+func (p *blockingPlugin) HandleMessage(ctx context.Context, df *pluginsv1.DFWrap) (*payload.DataFrameResponse, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return payload.NewSuccessDataFrameResponse(), nil
+}
+
+func (p *blockingPlugin) Close() error { return nil }
+
+func TestClient_Reconnect(t *testing.T) {
+	gateway := newFakeGateway(t)
+	defer gateway.Close()
+
+	defer viper.Reset()
+	viper.Set("auth.clientID", "app1")
+	viper.Set("auth.clientSecret", "secret1")
+	viper.Set("auth.openAPIHost", gateway.URL)
+
+	plugin := &blockingPlugin{started: make(chan struct{}, 1), release: make(chan struct{})}
+	pm := plugins.NewPluginManager()
+	pm.RegisterPlugin("http_plugin", plugin)
+
+	c := NewClient(nil, pm)
+	require.NoError(t, c.Connect(context.Background()))
+	old := gateway.accept(t)
+	require.Equal(t, "app1", old.clientID)
+
+	// 旧连接上有正在处理的回调
+	require.NoError(t, old.conn.WriteJSON(payload.DataFrame{
+		SpecVersion: "1.0",
+		Type:        "CALLBACK",
+		Headers: payload.DataFrameHeader{
+			payload.DataFrameHeaderKTopic:     callbackPath,
+			payload.DataFrameHeaderKMessageId: "msg-1",
+		},
+		Data: "{}",
+	}))
+	<-plugin.started
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Reconnect(&v1.AuthClientConfig{ClientID: "app2", ClientSecret: "secret2", OpenAPIHost: gateway.URL})
+	}()
+	current := gateway.accept(t)
+	require.Equal(t, "app2", current.clientID)
+
+	// 回调处理完成前不会关闭旧连接
+	select {
+	case <-done:
+		t.Fatal("回调未处理完成时关闭了旧连接")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(plugin.release)
+
+	// 回调的响应仍然通过旧连接返回
+	var response payload.DataFrameResponse
+	require.NoError(t, old.conn.ReadJSON(&response))
+	require.Equal(t, "msg-1", response.GetHeader(payload.DataFrameHeaderKMessageId))
+	require.NoError(t, <-done)
+
+	_, _, err := old.conn.ReadMessage()
+	require.Error(t, err)
+
+	// 旧凭证不会自动重连
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, 1, gateway.openCount("app1"))
+	require.Equal(t, "app2", c.conf.ClientID)
+
+	// 凭证没有变化时不重新连接
+	require.NoError(t, c.Reconnect(&v1.AuthClientConfig{ClientID: "app2", ClientSecret: "secret2", OpenAPIHost: gateway.URL}))
+	require.Equal(t, 1, gateway.openCount("app2"))
+
+	c.Disconnect()
+	_, _, err = current.conn.ReadMessage()
+	require.Error(t, err)
+}
+
+func TestClient_ReconnectUnlocked(t *testing.T) {
+	gateway := newFakeGateway(t)
+	defer gateway.Close()
+
+	defer viper.Reset()
+	viper.Set("auth.clientID", "app1")
+	viper.Set("auth.clientSecret", "secret1")
+	viper.Set("auth.openAPIHost", gateway.URL)
+
+	c := NewClient(nil, plugins.NewPluginManager())
+	require.NoError(t, c.Connect(context.Background()))
+	gateway.accept(t)
+
+	hold := make(chan struct{})
+	gateway.mu.Lock()
+	gateway.hold = hold
+	gateway.mu.Unlock()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Reconnect(&v1.AuthClientConfig{ClientID: "app2", ClientSecret: "secret2", OpenAPIHost: gateway.URL})
+	}()
+	require.Eventually(t, func() bool { return gateway.openCount("app2") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// 建立新连接期间可以查询状态
+	status := make(chan ConnectionStatus, 1)
+	go func() { status <- c.Status() }()
+	select {
+	case s := <-status:
+		require.Equal(t, "app1", s.ClientID)
+	case <-time.After(time.Second):
+		t.Fatal("建立连接期间查询状态被阻塞")
+	}
+
+	// 建立连接期间已断开时关闭新连接
+	c.Disconnect()
+	close(hold)
+	require.NoError(t, <-done)
+	current := gateway.accept(t)
+	require.Equal(t, "app2", current.clientID)
+	_, _, err := current.conn.ReadMessage()
+	require.Error(t, err)
+	require.False(t, c.Status().Connected)
+}
+
+func TestClient_ReconnectFailed(t *testing.T) {
+	gateway := newFakeGateway(t)
+	defer gateway.Close()
+
+	defer viper.Reset()
+	viper.Set("auth.clientID", "app1")
+	viper.Set("auth.clientSecret", "secret1")
+	viper.Set("auth.openAPIHost", gateway.URL)
+
+	c := NewClient(nil, plugins.NewPluginManager())
+	require.NoError(t, c.Connect(context.Background()))
+	old := gateway.accept(t)
+
+	// 新连接建立失败时继续使用旧连接
+	err := c.Reconnect(&v1.AuthClientConfig{ClientID: "app2", ClientSecret: "secret2", OpenAPIHost: "http://127.0.0.1:1"})
+	require.Error(t, err)
+	require.Equal(t, "app1", c.conf.ClientID)
+	require.NoError(t, old.conn.WriteMessage(websocket.PingMessage, nil))
+
+	c.Disconnect()
+}
+
+func TestClient_Status(t *testing.T) {
+	gateway := newFakeGateway(t)
+	defer gateway.Close()
+
+	defer viper.Reset()
+	viper.Set("auth.clientID", "app1")
+	viper.Set("auth.clientSecret", "secret1")
+	viper.Set("auth.openAPIHost", gateway.URL)
+
+	c := NewClient(nil, plugins.NewPluginManager())
+	require.False(t, c.Status().Connected)
+	require.NoError(t, c.Connect(context.Background()))
+	first := gateway.accept(t)
+
+	status := c.Status()
+	require.True(t, status.Connected)
+	require.Equal(t, "app1", status.ClientID)
+	require.NotNil(t, status.ConnectedAt)
+	require.Zero(t, status.Reconnects)
+
+	// 服务器要求断开后 SDK 自动重连
+	require.NoError(t, first.conn.WriteJSON(payload.DataFrame{
+		SpecVersion: "1.0",
+		Type:        "SYSTEM",
+		Headers: payload.DataFrameHeader{
+			payload.DataFrameHeaderKTopic:     "disconnect",
+			payload.DataFrameHeaderKMessageId: "msg-1",
+		},
+	}))
+	second := gateway.accept(t)
+	require.False(t, c.Status().Connected)
+
+	// 重连后收到 ping 时恢复为已连接
+	require.NoError(t, second.conn.WriteJSON(payload.DataFrame{
+		SpecVersion: "1.0",
+		Type:        "SYSTEM",
+		Headers: payload.DataFrameHeader{
+			payload.DataFrameHeaderKTopic:     "ping",
+			payload.DataFrameHeaderKMessageId: "msg-2",
+		},
+		Data: `{"opaque":"1"}`,
+	}))
+	var pong payload.DataFrameResponse
+	require.NoError(t, second.conn.ReadJSON(&pong))
+	require.Equal(t, "msg-2", pong.GetHeader(payload.DataFrameHeaderKMessageId))
+
+	status = c.Status()
+	require.True(t, status.Connected)
+	require.Equal(t, int64(1), status.Reconnects)
+
+	c.Disconnect()
+	require.False(t, c.Status().Connected)
+}
+
+func TestClient_IdleTimeout(t *testing.T) {
+	gateway := newFakeGateway(t)
+	defer gateway.Close()
+
+	defer viper.Reset()
+	viper.Set("auth.clientID", "app1")
+	viper.Set("auth.clientSecret", "secret1")
+	viper.Set("auth.openAPIHost", gateway.URL)
+
+	defer func(d time.Duration) { idleTimeout = d }(idleTimeout)
+	idleTimeout = 200 * time.Millisecond
+
+	c := NewClient(nil, plugins.NewPluginManager())
+	require.NoError(t, c.Connect(context.Background()))
+	first := gateway.accept(t)
+
+	// 长时间未收到服务器消息时关闭连接并重新连接
+	second := gateway.accept(t)
+	require.Equal(t, "app1", second.clientID)
+	_, _, err := first.conn.ReadMessage()
+	require.Error(t, err)
+	require.False(t, c.Status().Connected)
+
+	// 主动关闭后不再重新连接
+	c.Disconnect()
+	_, _, err = second.conn.ReadMessage()
+	require.Error(t, err)
+	time.Sleep(500 * time.Millisecond)
+	require.Equal(t, 2, gateway.openCount("app1"))
+}
+
+func TestClient_MessageLog(t *testing.T) {
+	var buf bytes.Buffer
+	out, formatter, level := logger.Log1.Out, logger.Log1.Formatter, logger.Log1.GetLevel()
+	defer func() {
+		logger.Log1.SetOutput(out)
+		logger.Log1.SetFormatter(formatter)
+		logger.Log1.SetLevel(level)
+	}()
+	logger.Log1.SetOutput(&buf)
+	logger.Log1.SetFormatter(&logrus.JSONFormatter{})
+	logger.Log1.SetLevel(logrus.InfoLevel)
+
+	df := &payload.DataFrame{
+		Headers: payload.DataFrameHeader{payload.DataFrameHeaderKMessageId: "msg-1"},
+		Data:    `{"specVersion":"2.0","pluginName":"mysql_plugin","data":{"sql":"SELECT phone FROM users","password":"root"}}`,
+	}
+	c := NewClient(nil, plugins.NewPluginManager())
+	c.handleServerMessage(context.Background(), df)
+
+	// info 级别只输出消息 id、插件和版本
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(strings.SplitN(buf.String(), "\n", 2)[0]), &entry))
+	require.Equal(t, "收到服务器消息", entry["msg"])
+	require.Equal(t, "msg-1", entry["messageId"])
+	require.Equal(t, "mysql_plugin", entry["plugin"])
+	require.Equal(t, "2.0", entry["dataVersion"])
+	require.NotContains(t, buf.String(), "SELECT")
+	require.NotContains(t, buf.String(), `"root"`)
+
+	// debug 级别以字段输出消息内容，密码按字段名脱敏
+	buf.Reset()
+	logger.Log1.SetLevel(logrus.DebugLevel)
+	c.handleServerMessage(context.Background(), df)
+	require.Contains(t, buf.String(), "SELECT phone FROM users")
+	require.NotContains(t, buf.String(), `"root"`)
+	require.Contains(t, buf.String(), logger.RedactMask)
+}
+
+func TestCheckCredentials(t *testing.T) {
+	gateway := newFakeGateway(t)
+	defer gateway.Close()
+
+	ctx := context.Background()
+	require.NoError(t, CheckCredentials(ctx, &v1.AuthClientConfig{ClientID: "app1", ClientSecret: "secret1", OpenAPIHost: gateway.URL}))
+	require.Equal(t, 1, gateway.openCount("app1"))
+	// 只检查凭证，不建立连接
+	select {
+	case <-gateway.conns:
+		t.Fatal("不应建立连接")
+	default:
+	}
+
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"invalidClientIdOrSecret"}`))
+	}))
+	defer denied.Close()
+	err := CheckCredentials(ctx, &v1.AuthClientConfig{ClientID: "app1", ClientSecret: "wrong", OpenAPIHost: denied.URL})
+	require.ErrorContains(t, err, "invalidClientIdOrSecret")
+}
@@ -1,19 +1,30 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
 	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 var (
 	glbEnvs map[string]string
 	mu      sync.RWMutex
+	// 最近一次校验通过的配置
+	clientConfig *v1.ClientCommonConfig
+	// 命令行 --config 指定的配置文件，为空时在默认目录中查找
+	configFile string
 )
 
 func init() {
@@ -34,11 +45,15 @@ func LoadConfig() error {
 
 	logger.Log1.Info("加载配置文件...")
 
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	// 添加配置文件路径
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./config")
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigName("config")
+		viper.SetConfigType("yaml")
+		// 添加配置文件路径
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("./config")
+	}
 
 	err := viper.ReadInConfig()
 	if err != nil {
@@ -46,6 +61,23 @@ func LoadConfig() error {
 		return err
 	}
 
+	// 展开 ${env:...} 等引用、解密 ENC(...) 格式的配置值并校验
+	prepared, err := prepareConfigFile(viper.ConfigFileUsed())
+	if err == nil {
+		err = prepared.apply()
+	}
+	if err != nil {
+		logger.Log1.Errorf("解析配置文件出错: %v", err)
+		return err
+	}
+	metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+
+	// 之后的日志写入配置的文件
+	if err := applyLogConfig(&clientConfig.Log); err != nil {
+		logger.Log1.Errorf("设置日志失败: %v", err)
+		return err
+	}
+
 	// 启用从环境变量读取配置
 	viper.AutomaticEnv()
 
@@ -59,28 +91,274 @@ func LoadConfig() error {
 	return nil
 }
 
-func WatchConfig(onChange func()) {
-	viper.WatchConfig()
-	viper.OnConfigChange(func(e fsnotify.Event) {
-		mu.Lock()
-		defer mu.Unlock()
-		logger.Log1.Infof("配置文件发生变化: %s", e.Name)
-		onChange()
+// SetConfigFile 指定配置文件路径，不再在默认目录中查找
+func SetConfigFile(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	configFile = path
+}
+
+// FindConfigFile 按 LoadConfig 的查找顺序返回配置文件路径
+func FindConfigFile() (string, error) {
+	mu.RLock()
+	file := configFile
+	mu.RUnlock()
+	if file != "" {
+		if _, err := os.Stat(file); err != nil {
+			return "", fmt.Errorf("未找到配置文件 %s", file)
+		}
+		return file, nil
+	}
+	for _, dir := range []string{".", "./config"} {
+		for _, name := range []string{"config.yaml", "config.yml"} {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+	}
+	return "", errors.New("未找到配置文件 config.yaml")
+}
+
+// 保存文件时可能连续产生多个事件，等待文件写完后再加载
+const reloadDelay = 300 * time.Millisecond
+
+// WatchConfig 监听配置文件变化。新配置解析和校验全部通过后才会生效，有误时继续使用当前配置；
+// 配置内容有变化时调用 onChange
+func WatchConfig(onChange func(diff *v1.ConfigDiff)) {
+	file := viper.ConfigFileUsed()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Log1.Errorf("监听配置文件失败: %v", err)
+		return
+	}
+	// 监听所在目录，编辑器和 Kubernetes ConfigMap 通过替换文件的方式更新
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		logger.Log1.Errorf("监听配置文件失败: %v", err)
+		watcher.Close()
+		return
+	}
+	go watchConfigFile(watcher, file, onChange)
+}
+
+func watchConfigFile(watcher *fsnotify.Watcher, file string, onChange func(diff *v1.ConfigDiff)) {
+	defer watcher.Close()
+	file = filepath.Clean(file)
+	realPath, _ := filepath.EvalSymlinks(file)
+
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			current, _ := filepath.EvalSymlinks(file)
+			written := filepath.Clean(event.Name) == file && event.Has(fsnotify.Write|fsnotify.Create)
+			if !written && (current == "" || current == realPath) {
+				continue
+			}
+			realPath = current
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDelay, func() {
+				logger.Log1.Infof("配置文件发生变化: %s", file)
+				diff, err := ReloadConfig()
+				if err != nil {
+					logger.Log1.Errorf("配置文件有误，继续使用当前配置: %v", err)
+					return
+				}
+				if diff.Empty() {
+					logger.Log1.Info("配置内容没有变化")
+					return
+				}
+				logConfigDiff(diff)
+				onChange(diff)
+			})
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Log1.Errorf("监听配置文件出错: %v", err)
+		}
+	}
+}
+
+// ReloadConfig 重新加载配置文件并返回与当前配置的差异；新配置有误时保留当前配置并返回错误
+func ReloadConfig() (diff *v1.ConfigDiff, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	defer func() {
+		if err != nil {
+			metrics.ConfigReloads.WithLabelValues("failure").Inc()
+			return
+		}
+		metrics.ConfigReloads.WithLabelValues("success").Inc()
+		metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	}()
+	prepared, err := prepareConfigFile(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	diff = v1.Diff(clientConfig, prepared.conf)
+	if err := prepared.apply(); err != nil {
+		return nil, err
+	}
+	if diff.LogChanged {
+		// 其它配置已生效，日志设置失败时继续使用原来的日志配置
+		if err := applyLogConfig(&clientConfig.Log); err != nil {
+			logger.Log1.Errorf("设置日志失败，继续使用原来的日志配置: %v", err)
+		}
+	}
+	return diff, nil
+}
+
+// applyLogConfig 按 log 部分设置日志文件、格式、级别和轮转，命令行参数优先
+func applyLogConfig(conf *v1.LogConfig) error {
+	return logger.Configure(logger.Options{
+		Dir:        conf.Dir,
+		Format:     conf.Format,
+		Level:      conf.Level,
+		App:        logger.FileOptions{File: conf.App.File, Level: conf.App.Level},
+		SDK:        logger.FileOptions{File: conf.SDK.File, Level: conf.SDK.Level},
+		MaxSize:    conf.MaxSize,
+		MaxBackups: conf.MaxBackups,
+		MaxAge:     conf.MaxAge,
+		Compress:   conf.Compress,
+		Redact: logger.RedactOptions{
+			Fields:         conf.Redact.Fields,
+			Patterns:       conf.Redact.Patterns,
+			CustomPatterns: conf.Redact.CustomPatterns,
+			MaxLength:      conf.Redact.MaxLength,
+			Debug:          conf.Redact.Debug,
+		},
 	})
 }
 
+func logConfigDiff(diff *v1.ConfigDiff) {
+	for _, pluginType := range diff.PluginTypes() {
+		d := diff.Plugin(pluginType)
+		logger.Log1.
+			WithField("plugin", pluginType).
+			WithField("added", d.Added).
+			WithField("removed", d.Removed).
+			WithField("changed", d.Changed).
+			WithField("authChanged", d.AuthChanged).
+			Info("插件配置已变化")
+	}
+	if diff.CredentialsChanged {
+		logger.Log1.Info("连接凭证已变化")
+	}
+	if diff.VaultChanged {
+		logger.Log1.Info("Vault 配置已变化")
+	}
+	if diff.LogChanged {
+		logger.Log1.Info("日志配置已变化")
+	}
+}
+
+// preparedConfig 解析和校验通过、尚未生效的配置
+type preparedConfig struct {
+	conf     *v1.ClientCommonConfig
+	resolved []byte
+}
+
+// prepareConfigFile 读取配置文件，升级旧格式、展开引用、解密并校验，不修改当前配置
+func prepareConfigFile(file string) (*preparedConfig, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 出错: %w", file, err)
+	}
+	changes, err := migrateConfig(&root)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		logger.Log1.
+			WithField("file", file).
+			WithField("changes", changes).
+			Warn("配置文件使用旧格式，已在内存中自动升级，可以执行 ipaas-agent config migrate 改写配置文件")
+	}
+
+	var tree map[string]interface{}
+	if err := root.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 出错: %w", file, err)
+	}
+	if _, err := resolveTree(tree); err != nil {
+		return nil, err
+	}
+	conf, err := validateConfig(file, &root, tree)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, err
+	}
+	return &preparedConfig{conf: conf, resolved: resolved}, nil
+}
+
+// ValidateConfigFile 按加载时的流程解析和校验配置文件，不修改当前配置
+func ValidateConfigFile(file string) (*v1.ClientCommonConfig, error) {
+	prepared, err := prepareConfigFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return prepared.conf, nil
+}
+
+// RenderConfig 返回配置文件升级格式、展开引用并解密后实际生效的内容，
+// redacted 为 true 时隐藏密码、密钥等敏感配置
+func RenderConfig(file string, redacted bool) ([]byte, error) {
+	prepared, err := prepareConfigFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{} = make(map[string]interface{})
+	if err := yaml.Unmarshal(prepared.resolved, &tree); err != nil {
+		return nil, err
+	}
+	if redacted {
+		tree = logger.RedactValue("", tree)
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(tree); err != nil {
+		return nil, err
+	}
+	encoder.Close()
+	return buf.Bytes(), nil
+}
+
+// apply 用新配置替换 viper 中的配置，调用方需持有 mu
+func (p *preparedConfig) apply() error {
+	if err := viper.ReadConfig(bytes.NewReader(p.resolved)); err != nil {
+		return err
+	}
+	clientConfig = p.conf
+	return nil
+}
+
+// GetClientConfig 返回最近一次加载并校验通过的配置
+func GetClientConfig() *v1.ClientCommonConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+	return clientConfig
+}
+
 func GetAuthClientConfig() *v1.AuthClientConfig {
 	mu.RLock()
 	defer mu.RUnlock()
 	auth := &v1.AuthClientConfig{
-		ClientID: FirstNonEmpty(
-			viper.GetString("auth.clientID"),
-			viper.GetString("client.client_id"), // 兼容旧版本
-		),
-		ClientSecret: FirstNonEmpty(
-			viper.GetString("auth.clientSecret"),
-			viper.GetString("client.client_secret"), // 兼容旧版本
-		),
+		// 旧版本的 client.client_id、client.client_secret 在加载时已迁移到 auth
+		ClientID:     viper.GetString("auth.clientID"),
+		ClientSecret: viper.GetString("auth.clientSecret"),
 		OpenAPIHost: FirstNonEmpty(
 			viper.GetString("auth.openAPIHost"),
 			"https://api.dingtalk.com",
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
@@ -80,3 +81,39 @@ plugins:
 	// require.Equal(t, "example", config.Plugins[1].ClientPluginOptions.(*v1.MySQLPluginOptions).Database)
 	// require.Equal(t, "default2", config.Plugins[1].ClientPluginOptions.(*v1.MySQLPluginOptions).ConfigKey)
 }
+
+func TestRenderConfig(t *testing.T) {
+	t.Setenv("RENDER_DB_PASSWORD", "render-pass")
+	file := filepath.Join(t.TempDir(), "agent.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+client:
+  client_id: ding123
+  client_secret: secret
+plugins:
+  mysql:
+    - host: localhost
+      password: ${env:RENDER_DB_PASSWORD}
+`), 0o600))
+
+	// 指定配置文件后不再在默认目录中查找
+	SetConfigFile(file)
+	defer SetConfigFile("")
+	found, err := FindConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, file, found)
+
+	content, err := RenderConfig(file, false)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "password: render-pass")
+	require.Contains(t, string(content), "clientID: ding123")
+
+	content, err = RenderConfig(file, true)
+	require.NoError(t, err)
+	require.NotContains(t, string(content), "render-pass")
+	require.Contains(t, string(content), "password: '******'")
+	require.Contains(t, string(content), "clientSecret: '******'")
+
+	SetConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	_, err = FindConfigFile()
+	require.Error(t, err)
+}
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+)
+
+// 旧版 mysql 配置的字段名和当前字段名的对应关系
+var legacyMySQLKeys = [][2]string{
+	{"addr", "address"},
+	{"username", "user"},
+}
+
+// migrateConfig 将旧格式的配置升级为当前格式，返回所做修改的说明；
+// 直接修改 YAML 节点，保留注释和每个配置项在原文件中的位置
+func migrateConfig(root *yaml.Node) ([]string, error) {
+	doc := documentRoot(root)
+	if doc == nil {
+		return nil, nil
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("配置文件的顶层应为对象")
+	}
+
+	version := 0
+	if _, v := mappingGet(doc, "version"); v != nil {
+		n, err := strconv.Atoi(v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("无效的配置版本 %q", v.Value)
+		}
+		version = n
+	}
+	if version > v1.CurrentConfigVersion {
+		return nil, fmt.Errorf("配置文件版本 %d 高于当前程序支持的版本 %d，请升级本地网关", version, v1.CurrentConfigVersion)
+	}
+	if version == v1.CurrentConfigVersion {
+		return nil, nil
+	}
+
+	var changes []string
+	changes = append(changes, migrateClient(doc)...)
+	changes = append(changes, migrateMySQL(doc)...)
+	if len(changes) > 0 {
+		setVersion(doc)
+		changes = append(changes, fmt.Sprintf("设置 version: %d", v1.CurrentConfigVersion))
+	}
+	return changes, nil
+}
+
+// migrateClient client.client_id、client.client_secret 迁移到 auth.clientID、auth.clientSecret
+func migrateClient(doc *yaml.Node) []string {
+	clientKey, client := mappingGet(doc, "client")
+	if client == nil {
+		return nil
+	}
+	renames := [][2]string{{"client_id", "clientID"}, {"client_secret", "clientSecret"}}
+
+	// 没有 auth 时直接将 client 改名为 auth，保留原来的位置和注释
+	if _, auth := mappingGet(doc, "auth"); auth == nil && client.Kind == yaml.MappingNode {
+		var changes []string
+		clientKey.Value = "auth"
+		for _, pair := range renames {
+			if key, _ := mappingGet(client, pair[0]); key != nil {
+				key.Value = pair[1]
+				changes = append(changes, fmt.Sprintf("client.%s 迁移到 auth.%s", pair[0], pair[1]))
+			}
+		}
+		return changes
+	}
+
+	var changes []string
+	var moved []*yaml.Node
+	auth := mappingEnsure(doc, "auth")
+	for _, pair := range renames {
+		key, value := mappingGet(client, pair[0])
+		if value == nil {
+			continue
+		}
+		if _, existing := mappingGet(auth, pair[1]); existing != nil {
+			changes = append(changes, fmt.Sprintf("删除 client.%s，已存在 auth.%s", pair[0], pair[1]))
+			continue
+		}
+		key.Value = pair[1]
+		moved = append(moved, key, value)
+		changes = append(changes, fmt.Sprintf("client.%s 迁移到 auth.%s", pair[0], pair[1]))
+	}
+	auth.Content = append(moved, auth.Content...)
+	mappingDelete(doc, "client")
+	return changes
+}
+
+// migrateMySQL 顶层 mysql 合并到 plugins.mysql，addr、username 改为 address、user
+func migrateMySQL(doc *yaml.Node) []string {
+	var changes []string
+	_, plugins := mappingGet(doc, "plugins")
+	if plugins != nil && plugins.Kind == yaml.MappingNode {
+		if _, list := mappingGet(plugins, "mysql"); list != nil {
+			changes = append(changes, renameMySQLKeys(list, "plugins.mysql")...)
+		}
+	}
+
+	_, legacy := mappingGet(doc, "mysql")
+	if legacy == nil {
+		return changes
+	}
+	changes = append(changes, renameMySQLKeys(legacy, "mysql")...)
+	if legacy.Kind == yaml.SequenceNode {
+		plugins = mappingEnsure(doc, "plugins")
+		list := mappingEnsureSequence(plugins, "mysql")
+		keys := make(map[string]bool)
+		for _, item := range list.Content {
+			keys[configKeyOf(item)] = true
+		}
+		for i, item := range legacy.Content {
+			key := configKeyOf(item)
+			if keys[key] {
+				changes = append(changes, fmt.Sprintf("删除 mysql[%d]，plugins.mysql 中已存在 config_key %q", i, key))
+				continue
+			}
+			keys[key] = true
+			list.Content = append(list.Content, item)
+			changes = append(changes, fmt.Sprintf("mysql[%d] 迁移到 plugins.mysql", i))
+		}
+	}
+	mappingDelete(doc, "mysql")
+	return changes
+}
+
+func renameMySQLKeys(list *yaml.Node, path string) []string {
+	if list.Kind != yaml.SequenceNode {
+		return nil
+	}
+	var changes []string
+	for i, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		for _, pair := range legacyMySQLKeys {
+			key, _ := mappingGet(item, pair[0])
+			if key == nil {
+				continue
+			}
+			if k, _ := mappingGet(item, pair[1]); k != nil {
+				mappingDelete(item, pair[0])
+				changes = append(changes, fmt.Sprintf("删除 %s[%d].%s，已存在 %s", path, i, pair[0], pair[1]))
+				continue
+			}
+			key.Value = pair[1]
+			changes = append(changes, fmt.Sprintf("%s[%d].%s 改为 %s", path, i, pair[0], pair[1]))
+		}
+	}
+	return changes
+}
+
+// configKeyOf 未配置 config_key 的 mysql 配置使用 default
+func configKeyOf(item *yaml.Node) string {
+	if _, v := mappingGet(item, "config_key"); v != nil && v.Value != "" {
+		return v.Value
+	}
+	return "default"
+}
+
+func setVersion(doc *yaml.Node) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(v1.CurrentConfigVersion)}
+	if _, v := mappingGet(doc, "version"); v != nil {
+		*v = *value
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if len(doc.Content) > 0 {
+		// 文件开头的注释仍然放在最前面
+		key.HeadComment, doc.Content[0].HeadComment = doc.Content[0].HeadComment, ""
+	}
+	doc.Content = append([]*yaml.Node{key, value}, doc.Content...)
+}
+
+func documentRoot(root *yaml.Node) *yaml.Node {
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return nil
+		}
+		return root.Content[0]
+	}
+	return root
+}
+
+func mappingGet(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// mappingDelete 删除配置项，配置项上方的注释保留到下一个配置项
+func mappingDelete(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			continue
+		}
+		if comment := node.Content[i].HeadComment; comment != "" && i+2 < len(node.Content) {
+			next := node.Content[i+2]
+			next.HeadComment = strings.TrimSpace(comment + "\n" + next.HeadComment)
+		}
+		node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		return
+	}
+}
+
+// mappingEnsure 返回 key 对应的对象，不存在或为空时创建
+func mappingEnsure(node *yaml.Node, key string) *yaml.Node {
+	return mappingEnsureKind(node, key, yaml.MappingNode, "!!map")
+}
+
+func mappingEnsureSequence(node *yaml.Node, key string) *yaml.Node {
+	return mappingEnsureKind(node, key, yaml.SequenceNode, "!!seq")
+}
+
+func mappingEnsureKind(node *yaml.Node, key string, kind yaml.Kind, tag string) *yaml.Node {
+	_, value := mappingGet(node, key)
+	if value == nil {
+		value = &yaml.Node{}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+	if value.Kind != kind {
+		// 例如 "auth:" 后没有内容
+		*value = yaml.Node{Kind: kind, Tag: tag}
+	}
+	return value
+}
+
+// MigrateConfigFile 将配置文件改写为当前格式，改写前备份原文件；
+// dryRun 为 true 时不写入文件，返回改写后的内容
+func MigrateConfigFile(path string, dryRun bool) (changes []string, migrated []byte, backup string, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, nil, "", fmt.Errorf("解析配置文件 %s 出错: %w", path, err)
+	}
+	if changes, err = migrateConfig(&root); err != nil {
+		return nil, nil, "", err
+	}
+	if doc := documentRoot(&root); doc != nil && len(changes) == 0 {
+		if _, v := mappingGet(doc, "version"); v == nil {
+			setVersion(doc)
+			changes = append(changes, fmt.Sprintf("设置 version: %d", v1.CurrentConfigVersion))
+		}
+	}
+	if len(changes) == 0 {
+		return nil, raw, "", nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, nil, "", err
+	}
+	encoder.Close()
+	migrated = buf.Bytes()
+	if dryRun {
+		return changes, migrated, "", nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	backup = path + ".bak." + time.Now().Format("20060102150405")
+	if err := os.WriteFile(backup, raw, info.Mode().Perm()); err != nil {
+		return nil, nil, "", fmt.Errorf("备份配置文件失败: %w", err)
+	}
+	if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+		return nil, nil, "", fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	return changes, migrated, backup, nil
+}
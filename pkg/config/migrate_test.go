@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+)
+
+const legacyConfig = `# 本地网关配置
+client:
+  client_id: ding123 # AppKey
+  client_secret: secret
+mysql:
+  - addr: 127.0.0.1:3307
+    username: root
+    password: legacy-pass
+    database: demo
+    config_key: legacy
+  - addr: 127.0.0.1:3306
+    username: root
+    config_key: default
+plugins:
+  mysql:
+    - host: localhost
+      username: admin
+`
+
+func TestMigrateConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(legacyConfig), 0o600))
+
+	changes, migrated, backup, err := MigrateConfigFile(path, false)
+	require.NoError(t, err)
+	require.NotEmpty(t, changes)
+	require.Equal(t, `# 本地网关配置
+version: 2
+auth:
+  clientID: ding123 # AppKey
+  clientSecret: secret
+plugins:
+  mysql:
+    - host: localhost
+      user: admin
+    - address: 127.0.0.1:3307
+      user: root
+      password: legacy-pass
+      database: demo
+      config_key: legacy
+`, string(migrated))
+
+	original, err := os.ReadFile(backup)
+	require.NoError(t, err)
+	require.Equal(t, legacyConfig, string(original))
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, migrated, written)
+
+	// 已是最新格式时不再改写
+	changes, _, backup, err = MigrateConfigFile(path, false)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+	require.Empty(t, backup)
+
+	require.NoError(t, os.WriteFile(path, []byte("version: 3\n"), 0o600))
+	_, _, _, err = MigrateConfigFile(path, true)
+	require.ErrorContains(t, err, "版本 3")
+}
+
+func TestLoadConfig_Legacy(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(legacyConfig), 0o600))
+
+	defer viper.Reset()
+	require.NoError(t, LoadConfig())
+
+	// 文件本身不会被修改
+	raw, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, legacyConfig, string(raw))
+
+	require.Equal(t, "ding123", GetAuthClientConfig().ClientID)
+	require.Equal(t, "secret", GetAuthClientConfig().ClientSecret)
+	require.Equal(t, "127.0.0.1:3307", viper.GetString("plugins.mysql.1.address"))
+
+	conf := GetClientConfig()
+	require.Equal(t, "ding123", conf.Auth.ClientID)
+	require.Len(t, conf.Plugins, 2)
+	require.Equal(t, "admin", conf.Plugins[0].ClientPluginOptions.(*v1.MySQLPluginOptions).User)
+	require.Equal(t, "legacy", conf.Plugins[1].GetConfigKey())
+}
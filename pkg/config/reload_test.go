@@ -0,0 +1,160 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+)
+
+const reloadConfig = `
+version: 2
+auth:
+  clientID: ding123
+  clientSecret: secret
+plugins:
+  mysql:
+    - config_key: orders
+      host: db1
+    - config_key: users
+      host: db2
+  http:
+    - config_key: erp
+      base_url: https://erp.example.com
+`
+
+func TestReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(reloadConfig), 0o600))
+
+	defer viper.Reset()
+	require.NoError(t, LoadConfig())
+
+	// 内容没有变化
+	diff, err := ReloadConfig()
+	require.NoError(t, err)
+	require.True(t, diff.Empty())
+
+	require.NoError(t, os.WriteFile(file, []byte(`
+version: 2
+auth:
+  clientID: ding123
+  clientSecret: secret
+  mysql:
+    allow_remote: true
+plugins:
+  mysql:
+    - config_key: orders
+      host: db3
+    - config_key: reports
+      host: db4
+  http:
+    - config_key: erp
+      base_url: https://erp.example.com
+`), 0o600))
+	diff, err = ReloadConfig()
+	require.NoError(t, err)
+	require.False(t, diff.CredentialsChanged)
+	require.Equal(t, []string{v1.PluginMySQL}, diff.PluginTypes())
+	require.Equal(t, &v1.PluginDiff{
+		Added:       []string{"reports"},
+		Removed:     []string{"users"},
+		Changed:     []string{"orders"},
+		AuthChanged: true,
+	}, diff.Plugin(v1.PluginMySQL))
+	require.Nil(t, diff.Plugin(v1.PluginHTTP))
+	require.Equal(t, "db3", viper.GetString("plugins.mysql.0.host"))
+
+	// http_default 变化时只重新加载 http 插件的默认配置
+	require.NoError(t, os.WriteFile(file, []byte(`
+version: 2
+auth:
+  clientID: ding123
+  clientSecret: secret
+  mysql:
+    allow_remote: true
+plugins:
+  mysql:
+    - config_key: orders
+      host: db3
+    - config_key: reports
+      host: db4
+  http:
+    - config_key: erp
+      base_url: https://erp.example.com
+http_default:
+  retry:
+    max_attempts: 3
+`), 0o600))
+	diff, err = ReloadConfig()
+	require.NoError(t, err)
+	require.Equal(t, []string{v1.PluginHTTP}, diff.PluginTypes())
+	require.Equal(t, &v1.PluginDiff{DefaultChanged: true}, diff.Plugin(v1.PluginHTTP))
+
+	// 新配置有误时继续使用当前配置
+	require.NoError(t, os.WriteFile(file, []byte(`
+version: 2
+auth:
+  clientID: ding456
+plugins:
+  mysql:
+    - config_key: orders
+      hots: db5
+`), 0o600))
+	_, err = ReloadConfig()
+	require.Error(t, err)
+	require.Equal(t, "ding123", GetAuthClientConfig().ClientID)
+	require.Equal(t, "db3", viper.GetString("plugins.mysql.0.host"))
+	require.Len(t, GetClientConfig().Plugins, 3)
+
+	require.NoError(t, os.WriteFile(file, []byte(`
+version: 2
+auth:
+  clientID: ding456
+  clientSecret: secret
+`), 0o600))
+	diff, err = ReloadConfig()
+	require.NoError(t, err)
+	require.True(t, diff.CredentialsChanged)
+	require.Equal(t, []string{"erp"}, diff.Plugin(v1.PluginHTTP).Removed)
+	require.Equal(t, "ding456", GetAuthClientConfig().ClientID)
+}
+
+func TestReloadConfig_Log(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(reloadConfig), 0o600))
+
+	defer viper.Reset()
+	defer logger.Configure(logger.Options{})
+	require.NoError(t, LoadConfig())
+	require.Equal(t, logrus.InfoLevel, logger.Log1.GetLevel())
+
+	require.NoError(t, os.WriteFile(file, []byte(reloadConfig+`
+log:
+  level: warn
+  app:
+    level: debug
+`), 0o600))
+	diff, err := ReloadConfig()
+	require.NoError(t, err)
+	require.True(t, diff.LogChanged)
+	require.Empty(t, diff.Plugins)
+	require.Equal(t, logrus.DebugLevel, logger.Log1.GetLevel())
+	require.Equal(t, logrus.WarnLevel, logger.Log2.GetLevel())
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+)
+
+// 配置值中的引用: ${env:NAME}、${env:NAME:-default}、${file:/path}、${vault:mount/path#key}
+var referencePattern = regexp.MustCompile(`\$\{(env|file|vault):([^}]*)\}`)
+
+// valueResolver 遍历配置树，展开引用、解密 ENC(...) 值，并登记需要在日志中隐藏的值
+type valueResolver struct {
+	key     []byte
+	vault   *vaultClient
+	changed bool
+}
+
+func (r *valueResolver) resolve(node interface{}, path string, sensitive bool) (interface{}, error) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			resolved, err := r.resolve(child, joinPath(path, k), logger.IsSensitiveKey(k))
+			if err != nil {
+				return nil, err
+			}
+			v[k] = resolved
+		}
+	case []interface{}:
+		for i, child := range v {
+			resolved, err := r.resolve(child, fmt.Sprintf("%s[%d]", path, i), sensitive)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	case string:
+		resolved, err := r.resolveString(v, sensitive)
+		if err != nil {
+			return nil, fmt.Errorf("解析配置 %s 出错: %w", path, err)
+		}
+		return resolved, nil
+	}
+	return node, nil
+}
+
+func (r *valueResolver) resolveString(value string, sensitive bool) (string, error) {
+	// 从文件和 Vault 读取的一般都是密钥
+	secret := sensitive
+	if referencePattern.MatchString(value) {
+		var firstErr error
+		value = referencePattern.ReplaceAllStringFunc(value, func(ref string) string {
+			m := referencePattern.FindStringSubmatch(ref)
+			resolved, err := r.lookup(m[1], m[2])
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if m[1] != "env" {
+				secret = true
+			}
+			return resolved
+		})
+		if firstErr != nil {
+			return "", firstErr
+		}
+		r.changed = true
+	}
+
+	if IsEncrypted(value) {
+		if r.key == nil {
+			key, err := LoadSecretKey()
+			if err != nil {
+				return "", err
+			}
+			r.key = key
+		}
+		plaintext, err := DecryptSecret(r.key, value)
+		if err != nil {
+			return "", err
+		}
+		value, secret = plaintext, true
+		r.changed = true
+	}
+	if secret {
+		logger.RegisterSecret(value)
+	}
+	return value, nil
+}
+
+func (r *valueResolver) lookup(source, ref string) (string, error) {
+	switch source {
+	case "env":
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		if value, ok := os.LookupEnv(name); ok {
+			return value, nil
+		}
+		if hasDefault {
+			return def, nil
+		}
+		return "", fmt.Errorf("环境变量 %s 未设置", name)
+	case "file":
+		content, err := os.ReadFile(ref)
+		if err != nil {
+			return "", fmt.Errorf("读取密钥文件出错: %w", err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case "vault":
+		if r.vault == nil {
+			return "", fmt.Errorf("未配置 Vault，无法解析 ${vault:%s}", ref)
+		}
+		return r.vault.read(ref)
+	}
+	return "", fmt.Errorf("不支持的引用类型: %s", source)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// resolveTree 展开配置树中的引用并解密加密值，返回配置是否有变化
+func resolveTree(tree map[string]interface{}) (bool, error) {
+	r := &valueResolver{}
+
+	// Vault 的地址和令牌本身也可以引用环境变量或加密，需要先解析
+	if node, ok := tree["vault"]; ok {
+		resolved, err := r.resolve(node, "vault", false)
+		if err != nil {
+			return false, err
+		}
+		tree["vault"] = resolved
+	}
+	var vaultConf v1.VaultConfig
+	if node, ok := tree["vault"]; ok {
+		raw, err := yaml.Marshal(node)
+		if err != nil {
+			return false, err
+		}
+		if err := yaml.Unmarshal(raw, &vaultConf); err != nil {
+			return false, fmt.Errorf("解析 Vault 配置出错: %w", err)
+		}
+	}
+	r.vault = newVaultClient(vaultConf)
+
+	for k, node := range tree {
+		if k == "vault" {
+			continue
+		}
+		resolved, err := r.resolve(node, k, logger.IsSensitiveKey(k))
+		if err != nil {
+			return false, err
+		}
+		tree[k] = resolved
+	}
+	return r.changed, nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTree(t *testing.T) {
+	t.Setenv("TEST_DB_USER", "root")
+	t.Setenv("TEST_VAULT_TOKEN", "s.test-token")
+	secretFile := filepath.Join(t.TempDir(), "db")
+	require.NoError(t, os.WriteFile(secretFile, []byte("file-password\n"), 0o600))
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		require.Equal(t, "/v1/kv/data/ipaas/db", r.URL.Path)
+		w.Write([]byte(`{"data":{"data":{"password":"vault-password"},"metadata":{"version":1}}}`))
+	}))
+	defer vault.Close()
+
+	tree := map[string]interface{}{
+		"vault": map[string]interface{}{
+			"address": vault.URL,
+			"token":   "${env:TEST_VAULT_TOKEN}",
+		},
+		"plugins": map[string]interface{}{
+			"mysql": []interface{}{
+				map[string]interface{}{
+					"dsn":      "${env:TEST_DB_USER}:${vault:kv/ipaas/db#password}@tcp(localhost:3306)/db",
+					"password": "${file:" + secretFile + "}",
+					"port":     3306,
+				},
+				map[string]interface{}{
+					"password": "${vault:kv/ipaas/db#password}",
+					"user":     "${env:TEST_MISSING_USER:-admin}",
+				},
+			},
+		},
+	}
+	changed, err := resolveTree(tree)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	mysql := tree["plugins"].(map[string]interface{})["mysql"].([]interface{})
+	first := mysql[0].(map[string]interface{})
+	require.Equal(t, "root:vault-password@tcp(localhost:3306)/db", first["dsn"])
+	require.Equal(t, "file-password", first["password"])
+	require.Equal(t, 3306, first["port"])
+	second := mysql[1].(map[string]interface{})
+	require.Equal(t, "vault-password", second["password"])
+	require.Equal(t, "admin", second["user"])
+}
+
+func TestResolveTree_Errors(t *testing.T) {
+	_, err := resolveTree(map[string]interface{}{"password": "${env:TEST_MISSING_PASSWORD}"})
+	require.ErrorContains(t, err, "TEST_MISSING_PASSWORD")
+
+	_, err = resolveTree(map[string]interface{}{"password": "${file:/nonexistent/secret}"})
+	require.Error(t, err)
+
+	t.Setenv("VAULT_ADDR", "")
+	_, err = resolveTree(map[string]interface{}{"password": "${vault:kv/db#password}"})
+	require.ErrorContains(t, err, "Vault")
+}
+
+func TestLoadConfig_References(t *testing.T) {
+	t.Setenv("TEST_CLIENT_SECRET", "env-client-secret")
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(`
+client:
+  client_id: ding123
+  client_secret: ${env:TEST_CLIENT_SECRET}
+`), 0o600))
+
+	defer viper.Reset()
+	require.NoError(t, LoadConfig())
+	require.Equal(t, "env-client-secret", GetAuthClientConfig().ClientSecret)
+}
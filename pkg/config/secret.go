@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// SecretKeyEnv 加密密钥，优先级最高
+	SecretKeyEnv = "IPAAS_AGENT_SECRET_KEY"
+	// SecretKeyFileEnv 加密密钥文件路径
+	SecretKeyFileEnv = "IPAAS_AGENT_SECRET_KEY_FILE"
+	// DefaultSecretKeyFile 默认的加密密钥文件，位于工作目录
+	DefaultSecretKeyFile = "secret.key"
+)
+
+// ErrSecretKeyNotFound 配置中存在加密值但没有找到密钥
+var ErrSecretKeyNotFound = errors.New("未找到加密密钥，请设置环境变量 " + SecretKeyEnv + " 或 " + SecretKeyFileEnv + "，或在工作目录放置 " + DefaultSecretKeyFile)
+
+// IsEncrypted 判断配置值是否为 ENC(...) 格式的加密值
+func IsEncrypted(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, "ENC(") && strings.HasSuffix(value, ")")
+}
+
+// deriveKey 将任意长度的密钥材料转换为 AES-256 密钥
+func deriveKey(material []byte) []byte {
+	sum := sha256.Sum256(bytes.TrimSpace(material))
+	return sum[:]
+}
+
+// LoadSecretKey 按环境变量、密钥文件环境变量、默认密钥文件的顺序读取密钥，都不存在时返回 ErrSecretKeyNotFound
+func LoadSecretKey() ([]byte, error) {
+	if key := os.Getenv(SecretKeyEnv); key != "" {
+		return deriveKey([]byte(key)), nil
+	}
+	path := os.Getenv(SecretKeyFileEnv)
+	if path == "" {
+		if _, err := os.Stat(DefaultSecretKeyFile); err != nil {
+			return nil, ErrSecretKeyNotFound
+		}
+		path = DefaultSecretKeyFile
+	}
+	return LoadSecretKeyFile(path)
+}
+
+// LoadSecretKeyFile 从文件读取密钥
+func LoadSecretKeyFile(path string) ([]byte, error) {
+	material, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取加密密钥文件出错: %w", err)
+	}
+	if len(bytes.TrimSpace(material)) == 0 {
+		return nil, fmt.Errorf("加密密钥文件为空: %s", path)
+	}
+	return deriveKey(material), nil
+}
+
+// EncryptSecret 使用 AES-256-GCM 加密，返回 ENC(...) 格式的配置值
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return "ENC(" + base64.StdEncoding.EncodeToString(sealed) + ")", nil
+}
+
+// DecryptSecret 解密 ENC(...) 格式的配置值
+func DecryptSecret(key []byte, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !IsEncrypted(value) {
+		return "", errors.New("不是 ENC(...) 格式的加密值")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len("ENC(") : len(value)-1])
+	if err != nil {
+		return "", fmt.Errorf("加密值不是有效的 base64: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("加密值长度不正确")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("解密失败，密钥不正确或加密值已损坏")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
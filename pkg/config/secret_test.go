@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	key := deriveKey([]byte("test-key"))
+	encrypted, err := EncryptSecret(key, "p@ssw0rd")
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(encrypted))
+
+	plaintext, err := DecryptSecret(key, encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "p@ssw0rd", plaintext)
+
+	_, err = DecryptSecret(deriveKey([]byte("other-key")), encrypted)
+	require.Error(t, err)
+}
+
+func TestLoadConfig_EncryptedValues(t *testing.T) {
+	t.Setenv(SecretKeyEnv, "test-key")
+	key, err := LoadSecretKey()
+	require.NoError(t, err)
+	password, err := EncryptSecret(key, "db-password")
+	require.NoError(t, err)
+	secret, err := EncryptSecret(key, "client-secret-value")
+	require.NoError(t, err)
+
+	// LoadConfig 从工作目录查找 config.yaml
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+client:
+  client_id: ding123
+  client_secret: `+secret+`
+plugins:
+  mysql:
+    - host: localhost
+      password: `+password+`
+      config_key: default
+`), 0o600))
+
+	defer viper.Reset()
+	require.NoError(t, LoadConfig())
+
+	require.Equal(t, "client-secret-value", GetAuthClientConfig().ClientSecret)
+	var mysql []map[string]interface{}
+	require.NoError(t, viper.UnmarshalKey("plugins.mysql", &mysql))
+	require.Equal(t, "db-password", mysql[0]["password"])
+
+	// 解密后的值不会出现在日志中
+	require.Equal(t, "dsn root:"+logger.RedactMask+"@tcp(localhost)", logger.RedactString("dsn root:db-password@tcp(localhost)"))
+
+	// 密钥错误时加载失败
+	t.Setenv(SecretKeyEnv, "wrong-key")
+	require.Error(t, LoadConfig())
+}
@@ -1,19 +1,170 @@
 package v1
 
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	pluginsv1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+)
+
 // type ClientConfig struct {
 // 	ClientCommonConfig
 // }
 
+// CurrentConfigVersion 当前的配置文件格式版本，旧格式在加载时自动升级
+const CurrentConfigVersion = 2
+
+// DefaultAdminAddress 管理接口默认只监听本机
+const DefaultAdminAddress = "127.0.0.1:8089"
+
+// DefaultTracingEndpoint 默认的 OTLP/HTTP 接收地址
+const DefaultTracingEndpoint = "http://127.0.0.1:4318"
+
+// DefaultAuditFile 默认的审计日志文件
+const DefaultAuditFile = "audit.log"
+
+// ClientCommonConfig 配置文件 config.yaml 的完整结构
 type ClientCommonConfig struct {
-	Auth    AuthClientConfig           `json:"auth"`
-	Proxies ProxyBaseConfig            `json:"proxies,omitempty"`
-	Plugins []TypedClientPluginOptions `json:"plugins,omitempty"`
+	Version int                        `json:"version,omitempty" mapstructure:"version"`
+	Auth    AuthConfig                 `json:"auth" mapstructure:"auth"`
+	Proxies ProxyBaseConfig            `json:"proxies,omitempty" mapstructure:"proxies"`
+	Plugins []TypedClientPluginOptions `json:"plugins,omitempty" mapstructure:"plugins"`
+	// 未指定 config_key 的 HTTP 请求的重试策略
+	HTTPDefault pluginsv1.HTTPDefaultConfig `json:"http_default,omitempty" mapstructure:"http_default"`
+	Vault       VaultConfig                 `json:"vault,omitempty" mapstructure:"vault"`
+	Admin       AdminConfig                 `json:"admin,omitempty" mapstructure:"admin"`
+	Tracing     TracingConfig               `json:"tracing,omitempty" mapstructure:"tracing"`
+	Audit       AuditConfig                 `json:"audit,omitempty" mapstructure:"audit"`
+	Log         LogConfig                   `json:"log,omitempty" mapstructure:"log"`
 }
 
 type AuthClientConfig struct {
-	ClientID     string `json:"clientID"`
-	ClientSecret string `json:"clientSecret"`
-	OpenAPIHost  string `json:"openAPIHost"`
+	ClientID     string `json:"clientID" mapstructure:"clientID"`
+	ClientSecret string `json:"clientSecret" mapstructure:"clientSecret"`
+	OpenAPIHost  string `json:"openAPIHost" mapstructure:"openAPIHost"`
+}
+
+// AuthConfig 配置文件 auth 部分，包含连接凭证和各插件的鉴权配置
+type AuthConfig struct {
+	AuthClientConfig `mapstructure:",squash"`
+
+	MySQL    PluginAuthConfig          `json:"mysql,omitempty" mapstructure:"mysql"`
+	MSSQL    PluginAuthConfig          `json:"mssql,omitempty" mapstructure:"mssql"`
+	PGSQL    PluginAuthConfig          `json:"pgsql,omitempty" mapstructure:"pgsql"`
+	OracleDB PluginAuthConfig          `json:"oracledb,omitempty" mapstructure:"oracledb"`
+	GRPC     PluginAuthConfig          `json:"grpc,omitempty" mapstructure:"grpc"`
+	HTTP     pluginsv1.HTTPGuardConfig `json:"http,omitempty" mapstructure:"http"`
+}
+
+// PluginAuthConfig auth.<插件> 部分
+type PluginAuthConfig struct {
+	AllowRemote bool `json:"allow_remote,omitempty" mapstructure:"allow_remote"`
+	// 仅 mssql 使用，拼接在连接串后的参数
+	LessCommonParameters string `json:"less_common_parameters,omitempty" mapstructure:"less_common_parameters"`
+	// 仅 grpc 使用，远程配置的连接是否使用 TLS
+	RemoteTLS bool `json:"remote_tls,omitempty" mapstructure:"remote_tls"`
+}
+
+// VaultConfig HashiCorp Vault 配置，未配置时使用 VAULT_ADDR、VAULT_TOKEN 环境变量
+type VaultConfig struct {
+	Address   string `json:"address,omitempty" yaml:"address" mapstructure:"address"`
+	Token     string `json:"token,omitempty" yaml:"token" mapstructure:"token"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace" mapstructure:"namespace"`
+	// KV 引擎版本，默认 2
+	KVVersion int `json:"kv_version,omitempty" yaml:"kv_version" mapstructure:"kv_version"`
+	// 请求超时时间，单位毫秒，默认 5000
+	Timeout int `json:"timeout,omitempty" yaml:"timeout" mapstructure:"timeout"`
+}
+
+// AdminConfig 本地管理接口，提供 /healthz、/readyz、/status
+type AdminConfig struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled" mapstructure:"enabled"`
+	// 监听地址，默认 127.0.0.1:8089
+	Address string `json:"address,omitempty" yaml:"address" mapstructure:"address"`
+}
+
+// TracingConfig OpenTelemetry 链路追踪，通过 OTLP/HTTP 导出
+type TracingConfig struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled" mapstructure:"enabled"`
+	// OTLP/HTTP 接收地址，默认 http://127.0.0.1:4318，导出到 <endpoint>/v1/traces
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint" mapstructure:"endpoint"`
+	// 导出时附加的请求头，例如鉴权
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers" mapstructure:"headers"`
+	// 采样比例 0-1，未配置时为 1 (全部采样)，0 表示只传递上下文不主动采样；
+	// 平台传入的 traceparent 已采样时始终采样
+	SampleRatio *float64 `json:"sample_ratio,omitempty" yaml:"sample_ratio" mapstructure:"sample_ratio"`
+	// 默认 ipaas-agent
+	ServiceName string `json:"service_name,omitempty" yaml:"service_name" mapstructure:"service_name"`
+	// 导出超时时间，单位毫秒，默认 10000
+	Timeout int `json:"timeout,omitempty" yaml:"timeout" mapstructure:"timeout"`
+}
+
+// AuditConfig 审计日志，每条回调消息记录一行 JSON，与运行日志分开保存
+type AuditConfig struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled" mapstructure:"enabled"`
+	// 默认 audit.log
+	File string `json:"file,omitempty" yaml:"file" mapstructure:"file"`
+	// 单个文件的最大大小，单位 MB，默认 100
+	MaxSize int `json:"max_size,omitempty" yaml:"max_size" mapstructure:"max_size"`
+	// 保留的旧文件个数，默认 0 (全部保留)
+	MaxBackups int `json:"max_backups,omitempty" yaml:"max_backups" mapstructure:"max_backups"`
+	// 旧文件保留天数，默认 0 (不按时间删除)
+	MaxAge int `json:"max_age,omitempty" yaml:"max_age" mapstructure:"max_age"`
+	// 是否使用 gzip 压缩旧文件
+	Compress bool `json:"compress,omitempty" yaml:"compress" mapstructure:"compress"`
+	// 配置后对每条记录签名并链接上一条记录，用于发现篡改，至少 16 个字符
+	HMACKey string `json:"hmac_key,omitempty" yaml:"hmac_key" mapstructure:"hmac_key"`
+}
+
+// LogConfig 运行日志，命令行参数 --log-dir、--log-format、--log-level 优先；
+// 修改后通过热加载立即生效
+type LogConfig struct {
+	// 日志文件目录，默认为当前目录
+	Dir string `json:"dir,omitempty" yaml:"dir" mapstructure:"dir"`
+	// text 或 json，默认 text
+	Format string `json:"format,omitempty" yaml:"format" mapstructure:"format"`
+	// trace、debug、info、warn、error，默认 info
+	Level string `json:"level,omitempty" yaml:"level" mapstructure:"level"`
+	// 本地网关的日志，默认 log1.txt
+	App LogFileConfig `json:"app,omitempty" yaml:"app" mapstructure:"app"`
+	// 连接 SDK 的日志，默认 log2.txt
+	SDK LogFileConfig `json:"sdk,omitempty" yaml:"sdk" mapstructure:"sdk"`
+	// 单个文件的最大大小，单位 MB，默认 100
+	MaxSize int `json:"max_size,omitempty" yaml:"max_size" mapstructure:"max_size"`
+	// 保留的旧文件个数，默认 0 (全部保留)
+	MaxBackups int `json:"max_backups,omitempty" yaml:"max_backups" mapstructure:"max_backups"`
+	// 旧文件保留天数，默认 0 (不按时间删除)
+	MaxAge int `json:"max_age,omitempty" yaml:"max_age" mapstructure:"max_age"`
+	// 是否使用 gzip 压缩旧文件
+	Compress bool `json:"compress,omitempty" yaml:"compress" mapstructure:"compress"`
+	// 日志脱敏
+	Redact LogRedactConfig `json:"redact,omitempty" yaml:"redact" mapstructure:"redact"`
+}
+
+// LogRedactConfig log.redact 部分，password、secret、token、Authorization 等字段始终隐藏
+type LogRedactConfig struct {
+	// 额外需要隐藏的字段名，比较时忽略大小写以及 _ 和 -
+	Fields []string `json:"fields,omitempty" yaml:"fields" mapstructure:"fields"`
+	// 按格式脱敏的个人信息: phone、id_card、email，默认全部
+	Patterns []string `json:"patterns,omitempty" yaml:"patterns" mapstructure:"patterns"`
+	// 自定义的正则表达式，匹配的内容替换为 ******
+	CustomPatterns []string `json:"custom_patterns,omitempty" yaml:"custom_patterns" mapstructure:"custom_patterns"`
+	// 日志消息和字段值的最大长度，单位字节，默认 4096
+	MaxLength int `json:"max_length,omitempty" yaml:"max_length" mapstructure:"max_length"`
+	// 调试时查看完整内容，不再按格式脱敏和截断
+	Debug bool `json:"debug,omitempty" yaml:"debug" mapstructure:"debug"`
+}
+
+// LogFileConfig log.app、log.sdk 部分
+type LogFileConfig struct {
+	// 文件名，相对路径时位于 log.dir 目录下
+	File string `json:"file,omitempty" yaml:"file" mapstructure:"file"`
+	// 为空时使用 log.level
+	Level string `json:"level,omitempty" yaml:"level" mapstructure:"level"`
 }
 
 func (c *ClientCommonConfig) Complete() {
@@ -24,4 +175,173 @@ func (c *ClientCommonConfig) Complete() {
 	if c.Auth.OpenAPIHost == "" {
 		c.Auth.OpenAPIHost = "https://api.dingtalk.com"
 	}
+	if c.Admin.Address == "" {
+		c.Admin.Address = DefaultAdminAddress
+	}
+	if c.Tracing.Endpoint == "" {
+		c.Tracing.Endpoint = DefaultTracingEndpoint
+	}
+	if c.Tracing.SampleRatio == nil {
+		ratio := 1.0
+		c.Tracing.SampleRatio = &ratio
+	}
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = "ipaas-agent"
+	}
+	if c.Audit.File == "" {
+		c.Audit.File = DefaultAuditFile
+	}
+	if c.Audit.MaxSize == 0 {
+		c.Audit.MaxSize = 100
+	}
+	if c.Log.Format == "" {
+		c.Log.Format = "text"
+	}
+	if c.Log.Level == "" {
+		c.Log.Level = "info"
+	}
+	if c.Log.App.File == "" {
+		c.Log.App.File = "log1.txt"
+	}
+	if c.Log.SDK.File == "" {
+		c.Log.SDK.File = "log2.txt"
+	}
+	if c.Log.MaxSize == 0 {
+		c.Log.MaxSize = 100
+	}
+	if c.Log.Redact.MaxLength == 0 {
+		c.Log.Redact.MaxLength = logger.DefaultRedactMaxLength
+	}
+}
+
+// Validate 校验补全后的配置，返回全部问题
+func (c *ClientCommonConfig) Validate() []FieldError {
+	var errs []FieldError
+	if c.Version < 0 || c.Version > CurrentConfigVersion {
+		errs = append(errs, fieldError("version", "不支持的配置版本 %d", c.Version))
+	}
+	if c.Auth.ClientID == "" {
+		errs = append(errs, fieldError("auth.clientID", "缺少必填项"))
+	}
+	if c.Auth.ClientSecret == "" {
+		errs = append(errs, fieldError("auth.clientSecret", "缺少必填项"))
+	}
+	errs = append(errs, validateHTTPGuard("auth.http", &c.Auth.HTTP)...)
+
+	if c.Vault.KVVersion != 0 && c.Vault.KVVersion != 1 && c.Vault.KVVersion != 2 {
+		errs = append(errs, fieldError("vault.kv_version", "只支持 1 或 2，当前为 %d", c.Vault.KVVersion))
+	}
+	errs = append(errs, validateTimeout("vault.timeout", c.Vault.Timeout)...)
+	errs = append(errs, validateAddress("admin.address", c.Admin.Address)...)
+	errs = append(errs, validateTracing("tracing", &c.Tracing)...)
+	errs = append(errs, validateAudit("audit", &c.Audit)...)
+	errs = append(errs, validateLog("log", &c.Log)...)
+
+	// 同一类型的插件中 config_key 不能重复
+	counts := make(map[string]int)
+	seen := make(map[string]string)
+	for _, p := range c.Plugins {
+		path := fmt.Sprintf("plugins.%s[%d]", p.Type, counts[p.Type])
+		counts[p.Type]++
+		errs = append(errs, p.Validate(path)...)
+
+		key := p.GetConfigKey()
+		if key == "" {
+			continue
+		}
+		if first, ok := seen[p.Type+"/"+key]; ok {
+			errs = append(errs, fieldError(path+".config_key", "config_key %q 与 %s 重复", key, first))
+			continue
+		}
+		seen[p.Type+"/"+key] = path
+	}
+	return errs
+}
+
+func validateTracing(path string, conf *TracingConfig) []FieldError {
+	var errs []FieldError
+	if u, err := url.Parse(conf.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fieldError(path+".endpoint", "地址应为 http:// 或 https:// 开头的完整地址，当前为 %q", conf.Endpoint))
+	}
+	if ratio := conf.SampleRatio; ratio != nil && (*ratio < 0 || *ratio > 1) {
+		errs = append(errs, fieldError(path+".sample_ratio", "必须在 0-1 之间，当前为 %v", *ratio))
+	}
+	errs = append(errs, validateTimeout(path+".timeout", conf.Timeout)...)
+	return errs
+}
+
+func validateAudit(path string, conf *AuditConfig) []FieldError {
+	var errs []FieldError
+	if conf.MaxSize < 0 {
+		errs = append(errs, fieldError(path+".max_size", "不能为负数，当前为 %d", conf.MaxSize))
+	}
+	if conf.MaxBackups < 0 {
+		errs = append(errs, fieldError(path+".max_backups", "不能为负数，当前为 %d", conf.MaxBackups))
+	}
+	if conf.MaxAge < 0 {
+		errs = append(errs, fieldError(path+".max_age", "不能为负数，当前为 %d", conf.MaxAge))
+	}
+	if conf.HMACKey != "" && len(conf.HMACKey) < 16 {
+		errs = append(errs, fieldError(path+".hmac_key", "至少需要 16 个字符"))
+	}
+	return errs
+}
+
+// logLevels 支持的日志级别
+var logLevels = map[string]bool{"trace": true, "debug": true, "info": true, "warn": true, "warning": true, "error": true}
+
+func validateLog(path string, conf *LogConfig) []FieldError {
+	var errs []FieldError
+	if conf.Format != "text" && conf.Format != "json" {
+		errs = append(errs, fieldError(path+".format", "只支持 text 或 json，当前为 %q", conf.Format))
+	}
+	for _, level := range []struct{ path, value string }{
+		{path + ".level", conf.Level},
+		{path + ".app.level", conf.App.Level},
+		{path + ".sdk.level", conf.SDK.Level},
+	} {
+		if level.value != "" && !logLevels[strings.ToLower(level.value)] {
+			errs = append(errs, fieldError(level.path, "无效的日志级别 %q，只支持 trace、debug、info、warn、error", level.value))
+		}
+	}
+	if conf.MaxSize < 0 {
+		errs = append(errs, fieldError(path+".max_size", "不能为负数，当前为 %d", conf.MaxSize))
+	}
+	if conf.MaxBackups < 0 {
+		errs = append(errs, fieldError(path+".max_backups", "不能为负数，当前为 %d", conf.MaxBackups))
+	}
+	if conf.MaxAge < 0 {
+		errs = append(errs, fieldError(path+".max_age", "不能为负数，当前为 %d", conf.MaxAge))
+	}
+	for i, name := range conf.Redact.Patterns {
+		if !slices.Contains(logger.BuiltinPatterns, name) {
+			errs = append(errs, fieldError(fmt.Sprintf("%s.redact.patterns[%d]", path, i), "未知的格式 %q，只支持 %s", name, strings.Join(logger.BuiltinPatterns, "、")))
+		}
+	}
+	for i, expr := range conf.Redact.CustomPatterns {
+		if _, err := regexp.Compile(expr); err != nil {
+			errs = append(errs, fieldError(fmt.Sprintf("%s.redact.custom_patterns[%d]", path, i), "无效的正则表达式: %v", err))
+		}
+	}
+	if conf.Redact.MaxLength < 0 {
+		errs = append(errs, fieldError(path+".redact.max_length", "不能为负数，当前为 %d", conf.Redact.MaxLength))
+	}
+	return errs
+}
+
+func validateHTTPGuard(path string, conf *pluginsv1.HTTPGuardConfig) []FieldError {
+	var errs []FieldError
+	for i, port := range conf.AllowPorts {
+		errs = append(errs, validatePort(fmt.Sprintf("%s.allow_ports[%d]", path, i), port)...)
+	}
+	for i, port := range conf.DenyPorts {
+		errs = append(errs, validatePort(fmt.Sprintf("%s.deny_ports[%d]", path, i), port)...)
+	}
+	for i, cidr := range conf.AllowCIDRs {
+		errs = append(errs, validateCIDR(fmt.Sprintf("%s.allow_cidrs[%d]", path, i), cidr)...)
+	}
+	for i, cidr := range conf.DenyCIDRs {
+		errs = append(errs, validateCIDR(fmt.Sprintf("%s.deny_cidrs[%d]", path, i), cidr)...)
+	}
+	return errs
 }
@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PluginDiff 某种插件两次加载之间按 config_key 比较的变化
+type PluginDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+	// auth.<插件> 部分发生变化，例如 allow_remote
+	AuthChanged bool
+	// http_default 部分发生变化，仅 http 插件
+	DefaultChanged bool
+}
+
+func (d *PluginDiff) Empty() bool {
+	return d == nil || len(d.Added)+len(d.Removed)+len(d.Changed) == 0 && !d.AuthChanged && !d.DefaultChanged
+}
+
+// Affects 判断 config_key 对应的配置是否被修改或删除
+func (d *PluginDiff) Affects(configKey string) bool {
+	if d == nil {
+		return false
+	}
+	for _, keys := range [][]string{d.Removed, d.Changed} {
+		for _, k := range keys {
+			if k == configKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *PluginDiff) String() string {
+	var parts []string
+	if len(d.Added) > 0 {
+		parts = append(parts, "新增 "+strings.Join(d.Added, ","))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, "删除 "+strings.Join(d.Removed, ","))
+	}
+	if len(d.Changed) > 0 {
+		parts = append(parts, "修改 "+strings.Join(d.Changed, ","))
+	}
+	if d.AuthChanged {
+		parts = append(parts, "鉴权配置变化")
+	}
+	if d.DefaultChanged {
+		parts = append(parts, "默认配置变化")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ConfigDiff 两次加载之间的配置变化
+type ConfigDiff struct {
+	// 只包含有变化的插件类型
+	Plugins map[string]*PluginDiff
+	// auth.clientID、auth.clientSecret、auth.openAPIHost 发生变化，需要重新连接
+	CredentialsChanged bool
+	VaultChanged       bool
+	// log 部分发生变化，需要重新设置日志
+	LogChanged bool
+}
+
+func (d *ConfigDiff) Empty() bool {
+	return len(d.Plugins) == 0 && !d.CredentialsChanged && !d.VaultChanged && !d.LogChanged
+}
+
+// Plugin 返回插件类型的变化，没有变化时返回 nil
+func (d *ConfigDiff) Plugin(pluginType string) *PluginDiff {
+	return d.Plugins[pluginType]
+}
+
+// PluginTypes 有变化的插件类型，按名称排序
+func (d *ConfigDiff) PluginTypes() []string {
+	types := make([]string, 0, len(d.Plugins))
+	for t := range d.Plugins {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Diff 比较两次加载的配置，old 为 nil 时所有配置都视为新增
+func Diff(old, new *ClientCommonConfig) *ConfigDiff {
+	if old == nil {
+		old = &ClientCommonConfig{}
+	}
+	diff := &ConfigDiff{
+		Plugins:            make(map[string]*PluginDiff),
+		CredentialsChanged: old.Auth.AuthClientConfig != new.Auth.AuthClientConfig,
+		VaultChanged:       old.Vault != new.Vault,
+		LogChanged:         !reflect.DeepEqual(old.Log, new.Log),
+	}
+
+	oldPlugins, newPlugins := pluginsByKey(old.Plugins), pluginsByKey(new.Plugins)
+	for pluginType := range ClientPluginOptionsTypeMap {
+		d := &PluginDiff{AuthChanged: !reflect.DeepEqual(old.Auth.plugin(pluginType), new.Auth.plugin(pluginType))}
+		if pluginType == PluginHTTP {
+			d.DefaultChanged = !reflect.DeepEqual(old.HTTPDefault, new.HTTPDefault)
+		}
+		before, after := oldPlugins[pluginType], newPlugins[pluginType]
+		for key, options := range after {
+			prev, ok := before[key]
+			switch {
+			case !ok:
+				d.Added = append(d.Added, key)
+			case !reflect.DeepEqual(prev, options):
+				d.Changed = append(d.Changed, key)
+			}
+		}
+		for key := range before {
+			if _, ok := after[key]; !ok {
+				d.Removed = append(d.Removed, key)
+			}
+		}
+		if !d.Empty() {
+			sort.Strings(d.Added)
+			sort.Strings(d.Removed)
+			sort.Strings(d.Changed)
+			diff.Plugins[pluginType] = d
+		}
+	}
+	return diff
+}
+
+// pluginsByKey 按插件类型和 config_key 索引配置，没有 config_key 的配置使用序号
+func pluginsByKey(plugins []TypedClientPluginOptions) map[string]map[string]ClientPluginOptions {
+	result := make(map[string]map[string]ClientPluginOptions)
+	for _, p := range plugins {
+		if result[p.Type] == nil {
+			result[p.Type] = make(map[string]ClientPluginOptions)
+		}
+		key := p.GetConfigKey()
+		if key == "" {
+			key = fmt.Sprintf("#%d", len(result[p.Type]))
+		}
+		result[p.Type][key] = p.ClientPluginOptions
+	}
+	return result
+}
+
+// plugin 返回插件类型对应的 auth 配置
+func (a *AuthConfig) plugin(pluginType string) interface{} {
+	switch pluginType {
+	case PluginMySQL:
+		return a.MySQL
+	case PluginMSSQL:
+		return a.MSSQL
+	case PluginPGSQL:
+		return a.PGSQL
+	case PluginOracleDB:
+		return a.OracleDB
+	case PluginGRPC:
+		return a.GRPC
+	case PluginHTTP:
+		return a.HTTP
+	}
+	return nil
+}
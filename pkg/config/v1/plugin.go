@@ -1,33 +1,75 @@
 package v1
 
 import (
+	"net/url"
 	"reflect"
+
+	pluginsv1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
 )
 
 type ClientPluginOptions interface {
 	Complete()
+	// Validate 校验补全后的配置，path 为该项在配置文件中的路径
+	Validate(path string) []FieldError
+	// GetConfigKey 引用键名，同一类型的插件中不能重复
+	GetConfigKey() string
 }
 
+// TypedClientPluginOptions 配置文件 plugins.<type>[] 中的一项
 type TypedClientPluginOptions struct {
 	Type string `json:"type"`
 	ClientPluginOptions
 }
 
 const (
-	PluginMySQL = "mysql"
+	PluginMySQL    = "mysql"
+	PluginMSSQL    = "mssql"
+	PluginPGSQL    = "pgsql"
+	PluginOracleDB = "oracledb"
+	PluginGRPC     = "grpc"
+	PluginHTTP     = "http"
 )
 
 var ClientPluginOptionsTypeMap = map[string]reflect.Type{
-	PluginMySQL: reflect.TypeOf(MySQLPluginOptions{}),
+	PluginMySQL:    reflect.TypeOf(MySQLPluginOptions{}),
+	PluginMSSQL:    reflect.TypeOf(MSSQLPluginOptions{}),
+	PluginPGSQL:    reflect.TypeOf(PGSQLPluginOptions{}),
+	PluginOracleDB: reflect.TypeOf(OracleDBPluginOptions{}),
+	PluginGRPC:     reflect.TypeOf(GRPCPluginOptions{}),
+	PluginHTTP:     reflect.TypeOf(HTTPPluginOptions{}),
+}
+
+// SQLPluginOptions 数据库插件的公共配置
+type SQLPluginOptions struct {
+	Host     string `json:"host,omitempty" mapstructure:"host"`
+	Port     int    `json:"port,omitempty" mapstructure:"port"`
+	Address  string `json:"address,omitempty" mapstructure:"address"`
+	User     string `json:"user,omitempty" mapstructure:"user"`
+	Password string `json:"password,omitempty" mapstructure:"password"`
+	Database string `json:"database,omitempty" mapstructure:"database"`
+	// oracle
+	ServiceName string `json:"service_name,omitempty" mapstructure:"service_name"`
+	SID         string `json:"sid,omitempty" mapstructure:"sid"`
+
+	ConfigKey  string `json:"config_key,omitempty" mapstructure:"config_key"`
+	ConnString string `json:"connection_str,omitempty" mapstructure:"connection_str"`
+}
+
+func (o *SQLPluginOptions) Complete() {}
+
+func (o *SQLPluginOptions) GetConfigKey() string {
+	return o.ConfigKey
+}
+
+func (o *SQLPluginOptions) Validate(path string) []FieldError {
+	var errs []FieldError
+	errs = append(errs, validatePort(path+".port", o.Port)...)
+	errs = append(errs, validateAddress(path+".address", o.Address)...)
+	return errs
 }
 
 type MySQLPluginOptions struct {
-	Host      string `json:"host,omitempty"`
-	Port      int    `json:"port,omitempty"`
-	Username  string `json:"username,omitempty"`
-	Password  string `json:"password,omitempty"`
-	Database  string `json:"database,omitempty"`
-	ConfigKey string `json:"configKey,omitempty"`
+	SQLPluginOptions `mapstructure:",squash"`
 }
 
 func (o *MySQLPluginOptions) Complete() {
@@ -41,3 +83,84 @@ func (o *MySQLPluginOptions) Complete() {
 		o.Host = "127.0.0.1"
 	}
 }
+
+type MSSQLPluginOptions struct {
+	SQLPluginOptions `mapstructure:",squash"`
+}
+
+type PGSQLPluginOptions struct {
+	SQLPluginOptions `mapstructure:",squash"`
+}
+
+type OracleDBPluginOptions struct {
+	SQLPluginOptions `mapstructure:",squash"`
+}
+
+func (o *OracleDBPluginOptions) Validate(path string) []FieldError {
+	errs := o.SQLPluginOptions.Validate(path)
+	if o.ConnString == "" && o.SID == "" && o.ServiceName == "" {
+		errs = append(errs, fieldError(path+".service_name", "service_name 和 sid 需要配置其中一个"))
+	}
+	return errs
+}
+
+// GRPCPluginOptions plugins.grpc[]
+type GRPCPluginOptions struct {
+	ConfigKey  string            `json:"config_key,omitempty" mapstructure:"config_key"`
+	Address    string            `json:"address,omitempty" mapstructure:"address"`
+	Protoset   string            `json:"protoset,omitempty" mapstructure:"protoset"`
+	Plaintext  bool              `json:"plaintext,omitempty" mapstructure:"plaintext"`
+	CAFile     string            `json:"ca_file,omitempty" mapstructure:"ca_file"`
+	ServerName string            `json:"server_name,omitempty" mapstructure:"server_name"`
+	Metadata   map[string]string `json:"metadata,omitempty" mapstructure:"metadata"`
+	Timeout    int               `json:"timeout,omitempty" mapstructure:"timeout"`
+}
+
+func (o *GRPCPluginOptions) Complete() {}
+
+func (o *GRPCPluginOptions) GetConfigKey() string {
+	return o.ConfigKey
+}
+
+func (o *GRPCPluginOptions) Validate(path string) []FieldError {
+	var errs []FieldError
+	if o.Address == "" {
+		errs = append(errs, fieldError(path+".address", "缺少必填项"))
+	}
+	errs = append(errs, validateAddress(path+".address", o.Address)...)
+	errs = append(errs, validateTimeout(path+".timeout", o.Timeout)...)
+	return errs
+}
+
+// HTTPPluginOptions plugins.http[]
+type HTTPPluginOptions struct {
+	pluginsv1.HTTPUpstreamConfig `mapstructure:",squash"`
+}
+
+func (o *HTTPPluginOptions) Complete() {}
+
+func (o *HTTPPluginOptions) GetConfigKey() string {
+	return o.ConfigKey
+}
+
+func (o *HTTPPluginOptions) Validate(path string) []FieldError {
+	var errs []FieldError
+	if o.ConfigKey == "" {
+		errs = append(errs, fieldError(path+".config_key", "缺少必填项"))
+	}
+	if o.BaseURL != "" {
+		if u, err := url.Parse(o.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fieldError(path+".base_url", "必须是完整的地址，当前为 %q", o.BaseURL))
+		}
+	}
+	switch o.Auth.Type {
+	case "", pluginsv1.HTTPAuthBasic, pluginsv1.HTTPAuthBearer, pluginsv1.HTTPAuthAPIKey, pluginsv1.HTTPAuthOAuth2, pluginsv1.HTTPAuthHMAC:
+	default:
+		errs = append(errs, fieldError(path+".auth.type", "不支持的鉴权方式 %q，可选 basic、bearer、api_key、oauth2、hmac", o.Auth.Type))
+	}
+	errs = append(errs, validateTimeout(path+".timeout", o.Timeout)...)
+	if o.MaxResponseSize < 0 {
+		errs = append(errs, fieldError(path+".max_response_size", "不能为负数"))
+	}
+	return errs
+}
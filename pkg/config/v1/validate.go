@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// FieldError 配置项的校验错误，Path 为 plugins.mysql[0].port 形式的路径
+type FieldError struct {
+	Path    string
+	Message string
+	// 配置文件中的位置，由加载配置时填充
+	Line   int
+	Column int
+}
+
+func (e FieldError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+func fieldError(path, format string, args ...interface{}) FieldError {
+	return FieldError{Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// validatePort 端口为 0 表示使用默认值
+func validatePort(path string, port int) []FieldError {
+	if port < 0 || port > 65535 {
+		return []FieldError{fieldError(path, "端口必须在 1-65535 之间，当前为 %d", port)}
+	}
+	return nil
+}
+
+// validateAddress 校验 host:port 格式的地址，地址为空时不校验
+func validateAddress(path, address string) []FieldError {
+	if address == "" {
+		return nil
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return []FieldError{fieldError(path, "地址格式应为 host:port，当前为 %q", address)}
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return []FieldError{fieldError(path, "端口必须在 1-65535 之间，当前为 %q", port)}
+	}
+	return nil
+}
+
+func validateCIDR(path, cidr string) []FieldError {
+	cidr = strings.TrimSpace(cidr)
+	if strings.Contains(cidr, "/") {
+		if _, _, err := net.ParseCIDR(cidr); err == nil {
+			return nil
+		}
+	} else if net.ParseIP(cidr) != nil {
+		return nil
+	}
+	return []FieldError{fieldError(path, "无效的 CIDR: %q", cidr)}
+}
+
+func validateTimeout(path string, timeout int) []FieldError {
+	if timeout < 0 {
+		return []FieldError{fieldError(path, "不能为负数")}
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+)
+
+// ValidationError 配置文件校验失败，包含全部问题
+type ValidationError struct {
+	File   string
+	Errors []v1.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "配置文件校验失败，共 %d 个问题:", len(e.Errors))
+	for _, fe := range e.Errors {
+		b.WriteString("\n  ")
+		if fe.Line > 0 {
+			fmt.Fprintf(&b, "%s:%d:%d: ", e.File, fe.Line, fe.Column)
+		}
+		b.WriteString(fe.Error())
+	}
+	return b.String()
+}
+
+var (
+	typedPluginsType = reflect.TypeOf([]v1.TypedClientPluginOptions{})
+	clientConfigType = reflect.TypeOf(v1.ClientCommonConfig{})
+)
+
+type position struct {
+	line, column int
+}
+
+// schemaChecker 对照配置结构检查 YAML 节点，记录每个配置项的位置
+type schemaChecker struct {
+	positions map[string]position
+	errs      []v1.FieldError
+}
+
+func (c *schemaChecker) addError(node *yaml.Node, path, format string, args ...interface{}) {
+	c.errs = append(c.errs, v1.FieldError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+		Line:    node.Line,
+		Column:  node.Column,
+	})
+}
+
+func (c *schemaChecker) walk(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return
+		}
+		node = node.Content[0]
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if _, ok := c.positions[strings.ToLower(path)]; !ok {
+		c.positions[strings.ToLower(path)] = position{node.Line, node.Column}
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == typedPluginsType {
+		c.walkPlugins(node, path)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			c.addError(node, path, "类型错误，应为对象")
+			return
+		}
+		fields := structFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := joinPath(path, key.Value)
+			c.positions[strings.ToLower(childPath)] = position{key.Line, key.Column}
+			field, ok := fields[strings.ToLower(key.Value)]
+			if !ok {
+				c.unknownKey(key, childPath, fields)
+				continue
+			}
+			c.walk(value, field.Type, childPath)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			c.addError(node, path, "类型错误，应为对象")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := joinPath(path, key.Value)
+			c.positions[strings.ToLower(childPath)] = position{key.Line, key.Column}
+			c.walk(value, t.Elem(), childPath)
+		}
+	case reflect.Slice, reflect.Array:
+		switch node.Kind {
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				c.walk(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		case yaml.ScalarNode:
+			// 与 viper 一致，单个值可以作为只有一项的列表
+			c.walk(node, t.Elem(), path)
+		default:
+			c.addError(node, path, "类型错误，应为列表")
+		}
+	case reflect.Interface:
+	default:
+		c.checkScalar(node, t, path)
+	}
+}
+
+// walkPlugins plugins 按插件类型分组，每种类型是一个列表
+func (c *schemaChecker) walkPlugins(node *yaml.Node, path string) {
+	if node.Kind != yaml.MappingNode {
+		c.addError(node, path, "类型错误，应为对象")
+		return
+	}
+	types := make(map[string]reflect.StructField, len(v1.ClientPluginOptionsTypeMap))
+	for name, t := range v1.ClientPluginOptionsTypeMap {
+		types[name] = reflect.StructField{Name: name, Type: reflect.SliceOf(t)}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		childPath := joinPath(path, key.Value)
+		c.positions[strings.ToLower(childPath)] = position{key.Line, key.Column}
+		field, ok := types[strings.ToLower(key.Value)]
+		if !ok {
+			c.unknownKey(key, childPath, types)
+			continue
+		}
+		c.walk(value, field.Type, childPath)
+	}
+}
+
+func (c *schemaChecker) unknownKey(key *yaml.Node, path string, fields map[string]reflect.StructField) {
+	candidates := make([]string, 0, len(fields))
+	for _, f := range fields {
+		candidates = append(candidates, f.Name)
+	}
+	if suggestion := suggest(key.Value, candidates); suggestion != "" {
+		c.addError(key, path, "未知的配置项 %q，是否应为 %q?", key.Value, suggestion)
+		return
+	}
+	c.addError(key, path, "未知的配置项 %q", key.Value)
+}
+
+// checkScalar 检查标量的类型，与 viper 一致允许字符串形式的数字和布尔值；引用和加密值在解析后再检查
+func (c *schemaChecker) checkScalar(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind != yaml.ScalarNode {
+		c.addError(node, path, "类型错误，应为单个值")
+		return
+	}
+	value := strings.TrimSpace(node.Value)
+	if referencePattern.MatchString(value) || IsEncrypted(value) {
+		return
+	}
+	var err error
+	var expected string
+	switch t.Kind() {
+	case reflect.Bool:
+		_, err = strconv.ParseBool(value)
+		expected = "布尔值"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, err = strconv.ParseInt(value, 0, 64)
+		expected = "整数"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		_, err = strconv.ParseUint(value, 0, 64)
+		expected = "非负整数"
+	case reflect.Float32, reflect.Float64:
+		_, err = strconv.ParseFloat(value, 64)
+		expected = "数字"
+	}
+	if err != nil {
+		c.addError(node, path, "类型错误，应为%s，当前为 %q", expected, node.Value)
+	}
+}
+
+// structFields 按 mapstructure 标签列出结构体的配置项，键为小写的配置名
+func structFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("mapstructure")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "squash") || (f.Anonymous && name == "") {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range structFields(ft) {
+					fields[k] = v
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		f.Name = name
+		fields[strings.ToLower(name)] = f
+	}
+	return fields
+}
+
+// suggest 返回与 key 最接近的候选项，差异过大时返回空字符串
+func suggest(key string, candidates []string) string {
+	normalize := func(s string) string {
+		return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+	}
+	sort.Strings(candidates)
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		d := levenshtein(normalize(key), normalize(candidate))
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if bestDistance < 0 || bestDistance > 2 && bestDistance > len(key)/3 {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// decodeClientConfig 按 viper 的规则将解析后的配置树转换为 v1.ClientCommonConfig
+func decodeClientConfig(tree map[string]interface{}) (*v1.ClientCommonConfig, error) {
+	conf := &v1.ClientCommonConfig{}
+	if err := decode(tree, conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func decode(input, output interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           output,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+			decodePluginsHook,
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
+// decodePluginsHook 将 plugins.<type>[] 转换为 []v1.TypedClientPluginOptions
+func decodePluginsHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != typedPluginsType {
+		return data, nil
+	}
+	groups, ok := data.(map[string]interface{})
+	if !ok {
+		return data, nil
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var plugins []v1.TypedClientPluginOptions
+	for _, name := range names {
+		t, ok := v1.ClientPluginOptionsTypeMap[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		items, ok := groups[name].([]interface{})
+		if !ok {
+			continue
+		}
+		for i, item := range items {
+			options := reflect.New(t)
+			if err := decode(item, options.Interface()); err != nil {
+				return nil, fmt.Errorf("plugins.%s[%d]: %w", name, i, err)
+			}
+			plugins = append(plugins, v1.TypedClientPluginOptions{
+				Type:                strings.ToLower(name),
+				ClientPluginOptions: options.Interface().(v1.ClientPluginOptions),
+			})
+		}
+	}
+	return plugins, nil
+}
+
+// validateConfig 检查配置文件的结构和取值，root 为配置文件的 YAML 节点，用于定位问题所在的行；
+// tree 为展开引用后的配置树。返回补全后的配置，或包含全部问题的 *ValidationError
+func validateConfig(file string, root *yaml.Node, tree map[string]interface{}) (*v1.ClientCommonConfig, error) {
+	checker := &schemaChecker{positions: make(map[string]position)}
+	checker.walk(root, clientConfigType, "")
+
+	// 去掉类型错误的配置项后继续检查取值，一次报告全部问题
+	errs := checker.errs
+	for _, fe := range errs {
+		removePath(tree, fe.Path)
+	}
+	conf, err := decodeClientConfig(tree)
+	switch {
+	case err != nil && len(errs) == 0:
+		errs = append(errs, v1.FieldError{Message: err.Error()})
+	case err == nil:
+		conf.Complete()
+		for _, fe := range conf.Validate() {
+			if reported(checker.errs, fe.Path) {
+				continue
+			}
+			// 补全的默认值在配置文件中没有位置，使用最近的上级配置项
+			for path := strings.ToLower(fe.Path); path != ""; path = parentPath(path) {
+				if pos, ok := checker.positions[path]; ok {
+					fe.Line, fe.Column = pos.line, pos.column
+					break
+				}
+			}
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return nil, &ValidationError{File: file, Errors: errs}
+	}
+	return conf, nil
+}
+
+// reported 配置项或其上级已经报告过结构问题
+func reported(errs []v1.FieldError, path string) bool {
+	for _, fe := range errs {
+		p := strings.ToLower(fe.Path)
+		path := strings.ToLower(path)
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// removePath 从配置树中删除 a.b[0].c 形式路径对应的配置项，键名不区分大小写
+func removePath(tree map[string]interface{}, path string) {
+	var node interface{} = tree
+	segments := strings.Split(strings.ReplaceAll(path, "[", ".["), ".")
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch n := node.(type) {
+		case map[string]interface{}:
+			key, ok := "", false
+			for k := range n {
+				if strings.EqualFold(k, seg) {
+					key, ok = k, true
+					break
+				}
+			}
+			if !ok {
+				return
+			}
+			if last {
+				delete(n, key)
+				return
+			}
+			node = n[key]
+		case []interface{}:
+			index, err := strconv.Atoi(strings.Trim(seg, "[]"))
+			if err != nil || index < 0 || index >= len(n) {
+				return
+			}
+			if last {
+				n[index] = nil
+				return
+			}
+			node = n[index]
+		default:
+			return
+		}
+	}
+}
+
+func parentPath(path string) string {
+	if i := strings.LastIndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+)
+
+func validate(t *testing.T, content string) (*v1.ClientCommonConfig, error) {
+	var root yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(content), &root))
+	_, err := migrateConfig(&root)
+	require.NoError(t, err)
+	var tree map[string]interface{}
+	require.NoError(t, root.Decode(&tree))
+	_, err = resolveTree(tree)
+	require.NoError(t, err)
+	return validateConfig("config.yaml", &root, tree)
+}
+
+func TestValidateConfig(t *testing.T) {
+	conf, err := validate(t, `
+client:
+  client_id: ding123
+  client_secret: secret
+plugins:
+  mysql:
+    - host: localhost
+      port: "3307"
+      user: root
+  mssql:
+    - address: localhost:1433
+      config_key: sqlServer
+  http:
+    - config_key: erp
+      base_url: https://erp.example.com/api
+      auth:
+        type: bearer
+        token: abc
+auth:
+  mysql:
+    allow_remote: true
+  http:
+    deny_ports: [22]
+admin:
+  enabled: true
+`)
+	require.NoError(t, err)
+	require.Equal(t, "ding123", conf.Auth.ClientID)
+	require.True(t, conf.Auth.MySQL.AllowRemote)
+	require.Equal(t, []int{22}, conf.Auth.HTTP.DenyPorts)
+	require.True(t, conf.Admin.Enabled)
+	require.Equal(t, v1.DefaultAdminAddress, conf.Admin.Address)
+	require.False(t, conf.Tracing.Enabled)
+	require.Equal(t, v1.DefaultTracingEndpoint, conf.Tracing.Endpoint)
+	require.Equal(t, 1.0, *conf.Tracing.SampleRatio)
+	require.Equal(t, v1.DefaultAuditFile, conf.Audit.File)
+	require.Equal(t, 100, conf.Audit.MaxSize)
+	require.Equal(t, "text", conf.Log.Format)
+	require.Equal(t, "info", conf.Log.Level)
+	require.Equal(t, "log1.txt", conf.Log.App.File)
+	require.Equal(t, "log2.txt", conf.Log.SDK.File)
+	require.Equal(t, 4096, conf.Log.Redact.MaxLength)
+
+	require.Len(t, conf.Plugins, 3)
+	require.Equal(t, v1.PluginHTTP, conf.Plugins[0].Type)
+	require.Equal(t, "https://erp.example.com/api", conf.Plugins[0].ClientPluginOptions.(*v1.HTTPPluginOptions).BaseURL)
+	mysql := conf.Plugins[2].ClientPluginOptions.(*v1.MySQLPluginOptions)
+	require.Equal(t, 3307, mysql.Port)
+	require.Equal(t, "default", mysql.ConfigKey)
+}
+
+func TestValidateConfig_SampleRatio(t *testing.T) {
+	// 显式配置为 0 时不采样，只传递上下文
+	conf, err := validate(t, `
+client:
+  client_id: ding123
+  client_secret: secret
+tracing:
+  enabled: true
+  sample_ratio: 0
+`)
+	require.NoError(t, err)
+	require.Zero(t, *conf.Tracing.SampleRatio)
+}
+
+func TestValidateConfig_Problems(t *testing.T) {
+	_, err := validate(t, `
+client:
+  client_id: ding123
+plugin:
+  mysql: []
+plugins:
+  mysql:
+    - host: localhost
+      usr: root
+      port: abc
+      config_key: db
+    - host: localhost
+      port: 70000
+      config_key: db
+  grpc:
+    - config_key: greeter
+  htp:
+    - config_key: erp
+auth:
+  http:
+    deny_cidrs: [10.0.0.0/33]
+`)
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+
+	type problem struct {
+		line int
+		path string
+	}
+	var problems []problem
+	for _, fe := range verr.Errors {
+		problems = append(problems, problem{fe.Line, fe.Path})
+	}
+	require.Equal(t, []problem{
+		{4, "plugin"},
+		{9, "plugins.mysql[0].usr"},
+		{10, "plugins.mysql[0].port"},
+		{13, "plugins.mysql[1].port"},
+		{14, "plugins.mysql[1].config_key"},
+		{16, "plugins.grpc[0].address"},
+		{17, "plugins.htp"},
+		{19, "auth.clientSecret"},
+		{21, "auth.http.deny_cidrs[0]"},
+	}, problems)
+	require.Contains(t, verr.Errors[0].Message, `"plugins"`)
+	require.Contains(t, verr.Errors[1].Message, `"user"`)
+	require.Contains(t, err.Error(), "config.yaml:10:13: plugins.mysql[0].port")
+
+	// 结构正确时检查取值
+	_, err = validate(t, `
+client:
+  client_id: ding123
+  client_secret: secret
+plugins:
+  mysql:
+    - host: localhost
+      port: 70000
+      config_key: db
+    - host: localhost
+      config_key: db
+`)
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 2)
+	require.Equal(t, "plugins.mysql[0].port", verr.Errors[0].Path)
+	require.Equal(t, 8, verr.Errors[0].Line)
+	require.Equal(t, "plugins.mysql[1].config_key", verr.Errors[1].Path)
+	require.Equal(t, 11, verr.Errors[1].Line)
+
+	_, err = validate(t, `
+client:
+  client_id: ding123
+  client_secret: secret
+tracing:
+  enabled: true
+  endpoint: 127.0.0.1:4318
+  sample_ratio: 2
+`)
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 2)
+	require.Equal(t, "tracing.endpoint", verr.Errors[0].Path)
+	require.Equal(t, "tracing.sample_ratio", verr.Errors[1].Path)
+
+	_, err = validate(t, `
+client:
+  client_id: ding123
+  client_secret: secret
+audit:
+  enabled: true
+  max_backups: -1
+  hmac_key: short
+`)
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 2)
+	require.Equal(t, "audit.max_backups", verr.Errors[0].Path)
+	require.Equal(t, "audit.hmac_key", verr.Errors[1].Path)
+
+	_, err = validate(t, `
+client:
+  client_id: ding123
+  client_secret: secret
+log:
+  format: xml
+  sdk:
+    level: verbose
+  redact:
+    patterns: [phone, bank]
+    custom_patterns: ["("]
+`)
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 4)
+	require.Equal(t, "log.format", verr.Errors[0].Path)
+	require.Equal(t, "log.sdk.level", verr.Errors[1].Path)
+	require.Equal(t, "log.redact.patterns[1]", verr.Errors[2].Path)
+	require.Equal(t, "log.redact.custom_patterns[0]", verr.Errors[3].Path)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(`
+client:
+  client_id: ding123
+  client_secret: secret
+plugins:
+  mysql:
+    - hots: localhost
+`), 0o600))
+
+	defer viper.Reset()
+	err = LoadConfig()
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Contains(t, err.Error(), `未知的配置项 "hots"，是否应为 "host"?`)
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+)
+
+// vaultClient 读取 Vault KV 中的密钥，同一次加载中相同路径只读取一次
+type vaultClient struct {
+	conf   v1.VaultConfig
+	client *http.Client
+	cache  map[string]map[string]interface{}
+}
+
+func newVaultClient(conf v1.VaultConfig) *vaultClient {
+	if conf.Address == "" {
+		conf.Address = os.Getenv("VAULT_ADDR")
+	}
+	if conf.Token == "" {
+		conf.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if conf.Namespace == "" {
+		conf.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if conf.KVVersion == 0 {
+		conf.KVVersion = 2
+	}
+	timeout := 5 * time.Second
+	if conf.Timeout > 0 {
+		timeout = time.Duration(conf.Timeout) * time.Millisecond
+	}
+	return &vaultClient{
+		conf:   conf,
+		client: &http.Client{Timeout: timeout},
+		cache:  make(map[string]map[string]interface{}),
+	}
+}
+
+// read 读取 mount/path#key 形式的引用
+func (c *vaultClient) read(ref string) (string, error) {
+	secretPath, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" || secretPath == "" {
+		return "", fmt.Errorf("Vault 引用格式应为 mount/path#key: %s", ref)
+	}
+	if c.conf.Address == "" {
+		return "", fmt.Errorf("未配置 Vault 地址，请设置 vault.address 或 VAULT_ADDR")
+	}
+
+	data, ok := c.cache[secretPath]
+	if !ok {
+		var err error
+		if data, err = c.fetch(secretPath); err != nil {
+			return "", err
+		}
+		c.cache[secretPath] = data
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("Vault 密钥 %s 中不存在 %s", secretPath, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+func (c *vaultClient) fetch(secretPath string) (map[string]interface{}, error) {
+	apiPath := strings.Trim(secretPath, "/")
+	if c.conf.KVVersion == 2 {
+		// KV v2 的读取路径为 <mount>/data/<path>
+		mount, rest, _ := strings.Cut(apiPath, "/")
+		apiPath = mount + "/data/" + rest
+	}
+	u, err := url.JoinPath(c.conf.Address, "v1", apiPath)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 Vault 地址: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", c.conf.Token)
+	if c.conf.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.conf.Namespace)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Vault 密钥 %s 失败: %w", secretPath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("读取 Vault 密钥 %s 失败: %s", secretPath, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("解析 Vault 响应失败: %w", err)
+	}
+	if c.conf.KVVersion == 2 {
+		inner, _ := body.Data["data"].(map[string]interface{})
+		return inner, nil
+	}
+	return body.Data, nil
+}
@@ -1,50 +1,247 @@
 package logger
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/open-dingtalk/ipaas-agent/pkg/ui"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var (
+	// Log1 本地网关的日志，默认写入 log1.txt
 	Log1 = logrus.New()
+	// Log2 连接 SDK 的日志，默认写入 log2.txt
 	Log2 = logrus.New()
 	once sync.Once
 )
 
+const (
+	DefaultAppFile = "log1.txt"
+	DefaultSDKFile = "log2.txt"
+	// DefaultMaxSize 单个日志文件的最大大小，单位 MB
+	DefaultMaxSize = 100
+)
+
+// FileOptions 单个日志文件的配置
+type FileOptions struct {
+	// 文件名，相对路径时位于 Dir 目录下
+	File string
+	// 日志级别，为空时使用 Options.Level
+	Level string
+}
+
+// Options 日志配置，未填写的项使用默认值
+type Options struct {
+	// 日志文件目录，默认为当前目录
+	Dir string
+	// text 或 json，默认 text
+	Format string
+	// trace、debug、info、warn、error，默认 info
+	Level string
+	App   FileOptions
+	SDK   FileOptions
+	// 按大小轮转，单位 MB，默认 100
+	MaxSize int
+	// 保留的旧文件个数，0 表示全部保留
+	MaxBackups int
+	// 旧文件保留天数，0 表示不按时间删除
+	MaxAge   int
+	Compress bool
+	// 日志脱敏，同时作用于两个日志
+	Redact RedactOptions
+}
+
+var (
+	configMu sync.Mutex
+	// 配置文件中的日志配置
+	current Options
+	// 命令行参数，优先于配置文件
+	override Options
+	// 正在使用的日志文件，配置不变时继续使用
+	files = make(map[*logrus.Logger]*logFile)
+)
+
 func init() {
 	InitLogger()
 }
 
+// InitLogger 使用默认配置初始化日志，文件在第一次写入时才会创建
 func InitLogger() {
-	// 设置第一个 Logger 输出到文件1
 	Log1.SetReportCaller(true)
-	file1, err := os.OpenFile("log1.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		logrus.Fatalf("Failed to open log file 1: %v", err)
-	}
-	Log1.Out = file1
-	Log1.SetLevel(logrus.InfoLevel)
-	Log1.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
-
-	// 设置第二个 Logger 输出到文件2
 	Log2.SetReportCaller(true)
-	file2, err := os.OpenFile("log2.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		logrus.Fatalf("Failed to open log file 2: %v", err)
+	if err := Configure(Options{}); err != nil {
+		logrus.Fatalf("初始化日志失败: %v", err)
 	}
-	Log2.Out = file2
-	Log2.SetLevel(logrus.InfoLevel)
-	Log2.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
 
-	// 使用 sync.Once 确保 ptermHook 只添加一次
+	// 使用 sync.Once 确保 Hook 只添加一次，脱敏需要在输出到终端之前执行
 	once.Do(func() {
+		Log1.AddHook(&RedactHook{})
+		Log2.AddHook(&RedactHook{})
 		Log1.AddHook(&ui.PtermHook{})
 	})
 }
+
+// Configure 应用配置文件中的日志配置，命令行参数指定的项优先；
+// 可以重复调用，文件和轮转配置不变时继续写入原来的文件
+func Configure(opts Options) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return apply(opts, override)
+}
+
+// SetOverride 设置命令行参数中的日志目录、格式和级别并立即生效，空值表示使用配置文件
+func SetOverride(dir, format, level string) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	o := Options{Dir: dir, Format: format, Level: level}
+	if err := apply(current, o); err != nil {
+		return err
+	}
+	override = o
+	return nil
+}
+
+// apply 合并配置并生效，有误时不做任何修改；调用方需持有 configMu
+func apply(opts, o Options) error {
+	merged := opts
+	if o.Dir != "" {
+		merged.Dir = o.Dir
+	}
+	if o.Format != "" {
+		merged.Format = o.Format
+	}
+	if o.Level != "" {
+		// 命令行指定的级别同时作用于两个日志
+		merged.Level = o.Level
+		merged.App.Level, merged.SDK.Level = "", ""
+	}
+	complete(&merged)
+
+	formatter, err := newFormatter(merged.Format)
+	if err != nil {
+		return err
+	}
+	appLevel, err := parseLevel(merged.App.Level, merged.Level)
+	if err != nil {
+		return err
+	}
+	sdkLevel, err := parseLevel(merged.SDK.Level, merged.Level)
+	if err != nil {
+		return err
+	}
+	redactor, err := newRedactor(merged.Redact)
+	if err != nil {
+		return err
+	}
+	if merged.Dir != "" {
+		if err := os.MkdirAll(merged.Dir, 0o755); err != nil {
+			return fmt.Errorf("创建日志目录失败: %w", err)
+		}
+	}
+
+	for _, l := range []struct {
+		logger *logrus.Logger
+		file   string
+		level  logrus.Level
+	}{
+		{Log1, merged.App.File, appLevel},
+		{Log2, merged.SDK.File, sdkLevel},
+	} {
+		setFile(l.logger, fileConfig{
+			path:       filePath(merged.Dir, l.file),
+			maxSize:    merged.MaxSize,
+			maxBackups: merged.MaxBackups,
+			maxAge:     merged.MaxAge,
+			compress:   merged.Compress,
+		})
+		l.logger.SetFormatter(formatter)
+		l.logger.SetLevel(l.level)
+	}
+	setRedaction(redactor)
+	current = opts
+	return nil
+}
+
+func complete(opts *Options) {
+	if opts.Format == "" {
+		opts.Format = "text"
+	}
+	if opts.Level == "" {
+		opts.Level = "info"
+	}
+	if opts.App.File == "" {
+		opts.App.File = DefaultAppFile
+	}
+	if opts.SDK.File == "" {
+		opts.SDK.File = DefaultSDKFile
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+}
+
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case "text":
+		return &logrus.TextFormatter{FullTimestamp: true}, nil
+	case "json":
+		return &logrus.JSONFormatter{}, nil
+	}
+	return nil, fmt.Errorf("无效的日志格式 %q，只支持 text 或 json", format)
+}
+
+func parseLevel(level, fallback string) (logrus.Level, error) {
+	if level == "" {
+		level = fallback
+	}
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return 0, fmt.Errorf("无效的日志级别 %q", level)
+	}
+	return lvl, nil
+}
+
+func filePath(dir, file string) string {
+	if dir == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+type fileConfig struct {
+	path       string
+	maxSize    int
+	maxBackups int
+	maxAge     int
+	compress   bool
+}
+
+type logFile struct {
+	config fileConfig
+	writer *lumberjack.Logger
+}
+
+// setFile 切换日志文件，新文件的权限为 0600；文件和轮转配置不变时继续使用原来的文件
+func setFile(logger *logrus.Logger, config fileConfig) {
+	old := files[logger]
+	if old != nil && old.config == config {
+		return
+	}
+	writer := &lumberjack.Logger{
+		Filename:   config.path,
+		MaxSize:    config.maxSize,
+		MaxBackups: config.maxBackups,
+		MaxAge:     config.maxAge,
+		Compress:   config.compress,
+	}
+	files[logger] = &logFile{config: config, writer: writer}
+	// SetOutput 持有 logger 的锁，返回后不会再写入旧文件
+	logger.SetOutput(writer)
+	if old != nil {
+		old.writer.Close()
+	}
+}
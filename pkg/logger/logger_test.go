@@ -0,0 +1,81 @@
+package logger_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	defer logger.Configure(logger.Options{})
+
+	require.NoError(t, logger.Configure(logger.Options{
+		Dir:    dir,
+		Format: "json",
+		Level:  "warn",
+		App:    logger.FileOptions{Level: "debug"},
+		SDK:    logger.FileOptions{File: "sdk.log"},
+	}))
+	require.Equal(t, logrus.DebugLevel, logger.Log1.GetLevel())
+	require.Equal(t, logrus.WarnLevel, logger.Log2.GetLevel())
+
+	// 文件在第一次写入时创建
+	_, err := os.Stat(filepath.Join(dir, "sdk.log"))
+	require.True(t, os.IsNotExist(err))
+
+	logger.Log1.WithField("configKey", "orders").Debug("调试信息")
+	logger.Log2.Info("不输出")
+	logger.Log2.Warn("连接断开")
+
+	content, err := os.ReadFile(filepath.Join(dir, logger.DefaultAppFile))
+	require.NoError(t, err)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &entry))
+	require.Equal(t, "调试信息", entry["msg"])
+	require.Equal(t, "orders", entry["configKey"])
+
+	content, err = os.ReadFile(filepath.Join(dir, "sdk.log"))
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(content), "\n"))
+	require.Contains(t, string(content), "连接断开")
+
+	info, err := os.Stat(filepath.Join(dir, "sdk.log"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// 配置有误时不做修改
+	require.Error(t, logger.Configure(logger.Options{Dir: dir, Format: "xml"}))
+	require.Error(t, logger.Configure(logger.Options{Dir: dir, SDK: logger.FileOptions{Level: "verbose"}}))
+	require.Equal(t, logrus.DebugLevel, logger.Log1.GetLevel())
+}
+
+func TestSetOverride(t *testing.T) {
+	dir := t.TempDir()
+	defer logger.Configure(logger.Options{})
+	defer logger.SetOverride("", "", "")
+
+	require.NoError(t, logger.SetOverride(dir, "", "error"))
+	require.Error(t, logger.SetOverride(dir, "", "verbose"))
+
+	// 命令行参数优先于配置文件
+	require.NoError(t, logger.Configure(logger.Options{
+		Dir:   filepath.Join(dir, "ignored"),
+		Level: "debug",
+		App:   logger.FileOptions{Level: "trace"},
+	}))
+	require.Equal(t, logrus.ErrorLevel, logger.Log1.GetLevel())
+	require.Equal(t, logrus.ErrorLevel, logger.Log2.GetLevel())
+
+	logger.Log1.Error("写入命令行指定的目录")
+	_, err := os.Stat(filepath.Join(dir, logger.DefaultAppFile))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "ignored"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,319 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactMask 脱敏后的占位符
+const RedactMask = "******"
+
+// 需要脱敏的字段名，比较时忽略大小写以及 _ 和 -
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"passwd":        true,
+	"pwd":           true,
+	"secret":        true,
+	"clientsecret":  true,
+	"token":         true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"apikey":        true,
+	"authorization": true,
+	"cookie":        true,
+	"privatekey":    true,
+	"connectionstr": true,
+	"dsn":           true,
+	"hmackey":       true,
+}
+
+// 已知的密钥明文 (例如解密后的配置值)，出现在日志的任何位置都会被替换
+var (
+	secretsMu sync.Mutex
+	secrets   = make(map[string]struct{})
+	replacer  atomic.Pointer[strings.Replacer]
+)
+
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	return strings.NewReplacer("_", "", "-", "").Replace(key)
+}
+
+// IsSensitiveKey 判断字段名是否为密码、密钥等敏感字段
+func IsSensitiveKey(key string) bool {
+	k := normalizeKey(key)
+	if sensitiveKeys[k] {
+		return true
+	}
+	if r := redaction.Load(); r != nil && r.fields[k] {
+		return true
+	}
+	return strings.HasSuffix(k, "password") || strings.HasSuffix(k, "secret") || strings.HasSuffix(k, "token")
+}
+
+// RegisterSecret 登记需要在日志中隐藏的密钥明文，过短的值容易误伤普通内容，不做处理
+func RegisterSecret(value string) {
+	if len(value) < 4 {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if _, ok := secrets[value]; ok {
+		return
+	}
+	secrets[value] = struct{}{}
+
+	// 优先替换较长的值，避免部分替换
+	values := make([]string, 0, len(secrets))
+	for v := range secrets {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	oldnew := make([]string, 0, len(values)*2)
+	for _, v := range values {
+		oldnew = append(oldnew, v, RedactMask)
+	}
+	replacer.Store(strings.NewReplacer(oldnew...))
+}
+
+// RedactString 替换字符串中已登记的密钥明文
+func RedactString(s string) string {
+	if r := replacer.Load(); r != nil {
+		return r.Replace(s)
+	}
+	return s
+}
+
+// RedactValue 对任意值脱敏，结构体、切片和 map 会转换为 JSON 结构后按字段名脱敏
+func RedactValue(key string, value interface{}) interface{} {
+	if IsSensitiveKey(key) {
+		if value == nil || value == "" {
+			return value
+		}
+		return RedactMask
+	}
+	switch v := value.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case string:
+		return RedactString(v)
+	case error:
+		if msg := RedactString(v.Error()); msg != v.Error() {
+			return msg
+		}
+		return v
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return value
+	}
+	return redactTree(tree)
+}
+
+func redactTree(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if IsSensitiveKey(k) && child != nil && child != "" {
+				v[k] = RedactMask
+			} else {
+				v[k] = redactTree(child)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactTree(child)
+		}
+		return v
+	case string:
+		return RedactString(v)
+	}
+	return node
+}
+
+// RedactHook 在日志输出前隐藏密码、密钥等敏感信息，需要在其它 Hook 之前添加
+type RedactHook struct{}
+
+func (hook *RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook *RedactHook) Fire(entry *logrus.Entry) error {
+	r := redaction.Load()
+	entry.Message = r.text(RedactString(entry.Message))
+	for k, v := range entry.Data {
+		entry.Data[k] = r.value(RedactValue(k, v))
+	}
+	return nil
+}
+
+// DefaultRedactMaxLength 日志消息和字段值的默认最大长度，单位字节
+const DefaultRedactMaxLength = 4096
+
+// 内置的个人信息格式，按名称在 RedactOptions.Patterns 中启用
+var builtinPatterns = map[string]pattern{
+	// 手机号保留前 3 位和后 4 位
+	"phone": {regexp.MustCompile(`\b1[3-9]\d{9}\b`), func(s string) string {
+		return s[:3] + "****" + s[7:]
+	}},
+	// 18 位身份证号隐藏出生日期
+	"id_card": {regexp.MustCompile(`\b[1-9]\d{5}(?:19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`), func(s string) string {
+		return s[:6] + "********" + s[14:]
+	}},
+	// 邮箱保留用户名的第一个字符和域名
+	"email": {regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), func(s string) string {
+		at := strings.LastIndexByte(s, '@')
+		return s[:1] + "***" + s[at:]
+	}},
+}
+
+// BuiltinPatterns 内置的个人信息格式名称
+var BuiltinPatterns = []string{"phone", "id_card", "email"}
+
+// RedactOptions 日志脱敏配置
+type RedactOptions struct {
+	// 额外需要隐藏的字段名，比较时忽略大小写以及 _ 和 -
+	Fields []string
+	// 启用的内置格式: phone、id_card、email，为空时全部启用
+	Patterns []string
+	// 自定义的正则表达式，匹配的内容替换为 ******
+	CustomPatterns []string
+	// 日志消息和字段值的最大长度，超出部分截断，默认 4096
+	MaxLength int
+	// 调试时查看完整内容：不再按格式脱敏和截断，密码等敏感字段和已登记的密钥仍然隐藏
+	Debug bool
+}
+
+type pattern struct {
+	re   *regexp.Regexp
+	mask func(string) string
+}
+
+// redactor 按格式脱敏和截断，nil 时不做处理
+type redactor struct {
+	fields    map[string]bool
+	patterns  []pattern
+	maxLength int
+	debug     bool
+}
+
+// 当前的脱敏配置，由 Configure 设置
+var redaction atomic.Pointer[redactor]
+
+func newRedactor(opts RedactOptions) (*redactor, error) {
+	r := &redactor{
+		fields:    make(map[string]bool),
+		maxLength: opts.MaxLength,
+		debug:     opts.Debug,
+	}
+	if r.maxLength == 0 {
+		r.maxLength = DefaultRedactMaxLength
+	}
+	for _, field := range opts.Fields {
+		r.fields[normalizeKey(field)] = true
+	}
+	names := opts.Patterns
+	if len(names) == 0 {
+		names = BuiltinPatterns
+	}
+	for _, name := range names {
+		p, ok := builtinPatterns[name]
+		if !ok {
+			return nil, fmt.Errorf("未知的脱敏格式 %q，只支持 %s", name, strings.Join(BuiltinPatterns, "、"))
+		}
+		r.patterns = append(r.patterns, p)
+	}
+	for _, expr := range opts.CustomPatterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的脱敏正则表达式 %q: %w", expr, err)
+		}
+		r.patterns = append(r.patterns, pattern{re: re, mask: func(string) string { return RedactMask }})
+	}
+	return r, nil
+}
+
+// text 按格式脱敏并截断过长的内容
+func (r *redactor) text(s string) string {
+	if r == nil || r.debug {
+		return s
+	}
+	for _, p := range r.patterns {
+		s = p.re.ReplaceAllStringFunc(s, p.mask)
+	}
+	return r.truncate(s)
+}
+
+func (r *redactor) truncate(s string) string {
+	if r.maxLength <= 0 || len(s) <= r.maxLength {
+		return s
+	}
+	cut := r.maxLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...(已截断，共 %d 字节)", s[:cut], len(s))
+}
+
+// value 处理 RedactValue 的结果，结构体等转换后的内容过长时整体截断为字符串
+func (r *redactor) value(v interface{}) interface{} {
+	if r == nil || r.debug {
+		return v
+	}
+	switch val := v.(type) {
+	case string:
+		return r.text(val)
+	case error:
+		if msg := r.text(val.Error()); msg != val.Error() {
+			return msg
+		}
+		return val
+	case map[string]interface{}, []interface{}:
+		v = r.tree(val)
+		data, err := json.Marshal(v)
+		if err == nil && len(data) > r.maxLength {
+			return r.truncate(string(data))
+		}
+		return v
+	}
+	return v
+}
+
+func (r *redactor) tree(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = r.tree(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.tree(child)
+		}
+		return v
+	case string:
+		return r.text(v)
+	}
+	return node
+}
+
+// setRedaction 切换脱敏配置，开启调试模式时提醒
+func setRedaction(r *redactor) {
+	if old := redaction.Swap(r); r.debug && (old == nil || !old.debug) {
+		Log1.Warn("日志脱敏调试模式已开启，日志中会包含完整的请求内容和个人信息，排查完成后请关闭")
+	}
+}
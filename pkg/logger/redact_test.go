@@ -0,0 +1,94 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactHook(t *testing.T) {
+	type dbConfig struct {
+		Host     string `json:"host"`
+		Password string `json:"password"`
+	}
+	logger.RegisterSecret("s3cr3t-value")
+
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.Out = &buf
+	log.AddHook(&logger.RedactHook{})
+	log.
+		WithField("配置列表", []dbConfig{{Host: "db.local", Password: "root"}}).
+		WithField("client_secret", "abc").
+		WithField("error", errors.New("auth failed for s3cr3t-value")).
+		Infof("连接 %s", "user:s3cr3t-value@db.local")
+
+	out := buf.String()
+	require.NotContains(t, out, "s3cr3t-value")
+	require.NotContains(t, out, "password:root")
+	require.NotContains(t, out, "abc")
+	require.Contains(t, out, "db.local")
+	require.Contains(t, out, logger.RedactMask)
+}
+
+func TestRedactHook_Options(t *testing.T) {
+	defer logger.Configure(logger.Options{})
+
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.Out = &buf
+	log.Formatter = &logrus.JSONFormatter{}
+	log.AddHook(&logger.RedactHook{})
+	entry := func() map[string]interface{} {
+		var data map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &data))
+		buf.Reset()
+		return data
+	}
+
+	// 默认按格式脱敏手机号、身份证号和邮箱
+	log.
+		WithField("request", map[string]interface{}{"mobile": "13812345678", "note": "user@example.com"}).
+		Info("身份证 11010519491231002X，手机号 13812345678")
+	data := entry()
+	require.Equal(t, "身份证 110105********002X，手机号 138****5678", data["msg"])
+	require.Equal(t, map[string]interface{}{"mobile": "138****5678", "note": "u***@example.com"}, data["request"])
+
+	// 订单号等较长的数字不受影响
+	log.Info("订单 2024110513812345678")
+	require.Equal(t, "订单 2024110513812345678", entry()["msg"])
+
+	require.NoError(t, logger.Configure(logger.Options{Redact: logger.RedactOptions{
+		Fields:         []string{"bank_account"},
+		Patterns:       []string{"email"},
+		CustomPatterns: []string{`\bCARD-\d+\b`},
+		MaxLength:      32,
+	}}))
+	log.
+		WithField("bankAccount", "6222020000000000").
+		WithField("body", strings.Repeat("好", 20)).
+		WithField("payload", map[string]interface{}{"items": []interface{}{strings.Repeat("a", 40)}}).
+		Info("CARD-1234 13812345678")
+	data = entry()
+	require.Equal(t, "****** 13812345678", data["msg"])
+	require.Equal(t, logger.RedactMask, data["bankAccount"])
+	require.Equal(t, strings.Repeat("好", 10)+"...(已截断，共 60 字节)", data["body"])
+	require.Contains(t, data["payload"], "...(已截断")
+
+	// 调试模式不再按格式脱敏和截断，敏感字段仍然隐藏
+	require.NoError(t, logger.Configure(logger.Options{Redact: logger.RedactOptions{Debug: true}}))
+	log.WithField("password", "p@ssw0rd!").WithField("body", strings.Repeat("a", 5000)).Info("手机号 13812345678")
+	data = entry()
+	require.Equal(t, "手机号 13812345678", data["msg"])
+	require.Equal(t, logger.RedactMask, data["password"])
+	require.Len(t, data["body"], 5000)
+
+	require.Error(t, logger.Configure(logger.Options{Redact: logger.RedactOptions{Patterns: []string{"bank"}}}))
+	require.Error(t, logger.Configure(logger.Options{Redact: logger.RedactOptions{CustomPatterns: []string{"("}}}))
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ipaas_agent"
+
+// Registry 本地网关的指标，通过管理接口 /metrics 暴露
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	// CallbacksTotal 处理的回调消息数，result 为 success 或 error
+	CallbacksTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callbacks_total",
+		Help:      "处理的回调消息数",
+	}, []string{"plugin", "spec_version", "result"})
+	CallbackDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "callback_duration_seconds",
+		Help:      "处理回调消息的耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"plugin", "spec_version"})
+
+	SQLQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sql_query_duration_seconds",
+		Help:      "执行 SQL 的耗时，包含建立连接",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"plugin", "config_key", "result"})
+	SQLRows = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sql_rows",
+		Help:      "SQL 查询返回的行数",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"plugin", "config_key"})
+	// SQLInflight 正在执行的查询数，本地配置的连接数见 sql_pool_* 指标
+	SQLInflight = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sql_inflight_queries",
+		Help:      "正在执行的 SQL 查询数",
+	}, []string{"plugin", "config_key"})
+
+	// HTTPUpstreamResponses 上游返回的状态码，请求失败时 code 为 error
+	HTTPUpstreamResponses = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_upstream_responses_total",
+		Help:      "HTTP 上游的响应数",
+	}, []string{"config_key", "code"})
+	HTTPUpstreamOpenConnections = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_upstream_open_connections",
+		Help:      "HTTP 上游连接池中打开的连接数",
+	}, []string{"config_key"})
+	// HTTPUpstreamConnections 请求使用的连接，reused 表示是否复用了连接池中的连接
+	HTTPUpstreamConnections = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_upstream_connections_total",
+		Help:      "HTTP 上游请求获取的连接数",
+	}, []string{"config_key", "reused"})
+
+	GRPCConnections = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "grpc_connections",
+		Help:      "打开的 gRPC 连接数",
+	}, []string{"config_key"})
+
+	// Reconnects 重新连接到服务器的次数，reason 为 credentials (凭证变化) 或 server (服务器断开后自动重连)
+	Reconnects = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconnects_total",
+		Help:      "重新连接到服务器的次数",
+	}, []string{"reason"})
+
+	// ConfigReloads 配置热加载的结果，result 为 success 或 failure
+	ConfigReloads = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "配置热加载次数",
+	}, []string{"result"})
+	ConfigLastReloadSuccess = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "最近一次成功加载配置的时间",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		sqlPoolCollector{},
+	)
+}
+
+// Handler 返回 Prometheus 格式的指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ConfigKey 远程配置和未指定 config_key 的请求使用 remote 作为标签值；
+// 调用方需保证 key 是本地配置的 config_key，避免标签值无限增长
+func ConfigKey(key string) string {
+	if key == "" {
+		return "remote"
+	}
+	return key
+}
+
+// Result 根据错误返回 success 或 error
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// StatusCode 请求失败时返回 error
+func StatusCode(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	return strconv.Itoa(resp.StatusCode)
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SQLPoolStats 一个本地配置的 SQL 连接池的状态
+type SQLPoolStats struct {
+	Plugin    string
+	ConfigKey string
+	Stats     sql.DBStats
+}
+
+var (
+	sqlPoolLabels = []string{"plugin", "config_key"}
+
+	sqlPoolOpenDesc = prometheus.NewDesc(namespace+"_sql_pool_open_connections",
+		"SQL 连接池中打开的连接数", sqlPoolLabels, nil)
+	sqlPoolInUseDesc = prometheus.NewDesc(namespace+"_sql_pool_in_use_connections",
+		"SQL 连接池中正在使用的连接数", sqlPoolLabels, nil)
+	sqlPoolIdleDesc = prometheus.NewDesc(namespace+"_sql_pool_idle_connections",
+		"SQL 连接池中空闲的连接数", sqlPoolLabels, nil)
+	sqlPoolWaitCountDesc = prometheus.NewDesc(namespace+"_sql_pool_wait_total",
+		"等待 SQL 连接池中空闲连接的次数", sqlPoolLabels, nil)
+	sqlPoolWaitDurationDesc = prometheus.NewDesc(namespace+"_sql_pool_wait_duration_seconds_total",
+		"等待 SQL 连接池中空闲连接的总耗时", sqlPoolLabels, nil)
+
+	sqlPoolSource atomic.Pointer[func() []SQLPoolStats]
+)
+
+// SetSQLPoolSource 设置连接池状态的来源，每次抓取指标时调用
+func SetSQLPoolSource(source func() []SQLPoolStats) {
+	sqlPoolSource.Store(&source)
+}
+
+// sqlPoolCollector 抓取时读取 sql.DB.Stats，连接池关闭后对应的指标随之消失
+type sqlPoolCollector struct{}
+
+func (sqlPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sqlPoolOpenDesc
+	ch <- sqlPoolInUseDesc
+	ch <- sqlPoolIdleDesc
+	ch <- sqlPoolWaitCountDesc
+	ch <- sqlPoolWaitDurationDesc
+}
+
+func (sqlPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	source := sqlPoolSource.Load()
+	if source == nil {
+		return
+	}
+	for _, pool := range (*source)() {
+		labels := []string{pool.Plugin, pool.ConfigKey}
+		ch <- prometheus.MustNewConstMetric(sqlPoolOpenDesc, prometheus.GaugeValue, float64(pool.Stats.OpenConnections), labels...)
+		ch <- prometheus.MustNewConstMetric(sqlPoolInUseDesc, prometheus.GaugeValue, float64(pool.Stats.InUse), labels...)
+		ch <- prometheus.MustNewConstMetric(sqlPoolIdleDesc, prometheus.GaugeValue, float64(pool.Stats.Idle), labels...)
+		ch <- prometheus.MustNewConstMetric(sqlPoolWaitCountDesc, prometheus.CounterValue, float64(pool.Stats.WaitCount), labels...)
+		ch <- prometheus.MustNewConstMetric(sqlPoolWaitDurationDesc, prometheus.CounterValue, pool.Stats.WaitDuration.Seconds(), labels...)
+	}
+}
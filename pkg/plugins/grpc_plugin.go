@@ -0,0 +1,639 @@
+package plugins
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	"github.com/open-dingtalk/ipaas-agent/pkg/audit"
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+)
+
+// GRPCConfig 本地 gRPC 服务配置 plugins.grpc[]
+type GRPCConfig struct {
+	ConfigKey string `json:"config_key,omitempty" mapstructure:"config_key,omitempty"`
+	// 服务地址 host:port
+	Address string `json:"address,omitempty" mapstructure:"address,omitempty"`
+	// 本地 .protoset 文件 (protoc --descriptor_set_out --include_imports)，未配置时使用服务端反射
+	Protoset string `json:"protoset,omitempty" mapstructure:"protoset,omitempty"`
+	// 是否使用明文连接，默认使用 TLS
+	Plaintext  bool   `json:"plaintext,omitempty" mapstructure:"plaintext,omitempty"`
+	CAFile     string `json:"ca_file,omitempty" mapstructure:"ca_file,omitempty"`
+	ServerName string `json:"server_name,omitempty" mapstructure:"server_name,omitempty"`
+	// 每次调用附带的 metadata
+	Metadata map[string]string `json:"metadata,omitempty" mapstructure:"metadata,omitempty"`
+	// 默认超时时间，单位毫秒
+	Timeout int `json:"timeout,omitempty" mapstructure:"timeout,omitempty"`
+}
+
+// GRPCRequest 平台下发的 gRPC 调用参数
+type GRPCRequest struct {
+	ConfigKey string `json:"config_key,omitempty"`
+	// 仅在允许远程配置时生效
+	Address string `json:"address,omitempty"`
+	// 完整服务名 package.Service
+	Service string `json:"service,omitempty"`
+	// 方法名，也可以写成 package.Service/Method
+	Method string `json:"method,omitempty"`
+	// JSON 格式的请求消息
+	Request  json.RawMessage   `json:"request,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// 超时时间，单位毫秒
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// GRPCResponse gRPC 调用结果
+type GRPCResponse struct {
+	Code     int                 `json:"code"`
+	Status   string              `json:"status"`
+	Message  string              `json:"message,omitempty"`
+	Details  []interface{}       `json:"details,omitempty"`
+	Response interface{}         `json:"response,omitempty"`
+	Headers  map[string][]string `json:"headers,omitempty"`
+	Trailers map[string][]string `json:"trailers,omitempty"`
+}
+
+// grpcUpstream 每个 config_key 对应的连接和描述符缓存
+type grpcUpstream struct {
+	conf  GRPCConfig
+	conn  *grpc.ClientConn
+	mu    sync.Mutex
+	files *protoregistry.Files
+	// 反射得到的服务描述符缓存
+	services map[string]*protoregistry.Files
+
+	// 以下字段由 GRPCPlugin.mu 保护：正在使用连接的调用数，以及是否已从缓存中移除
+	refs    int
+	retired bool
+}
+
+type GRPCPlugin struct {
+	Name        string
+	AllowRemote bool
+	// 远程配置的连接是否使用 TLS，默认明文
+	RemoteTLS bool
+	Configs   []GRPCConfig
+
+	mu        sync.Mutex
+	upstreams map[string]*grpcUpstream
+}
+
+func NewGRPCPlugin() *GRPCPlugin {
+	return &GRPCPlugin{
+		Name:      "grpc_plugin",
+		upstreams: make(map[string]*grpcUpstream),
+	}
+}
+
+func (p *GRPCPlugin) Init() error {
+	var grpcConfigs []GRPCConfig
+
+	if err := viper.UnmarshalKey("plugins.grpc", &grpcConfigs); err != nil {
+		return fmt.Errorf("解析 gRPC 配置出错: %w", err)
+	}
+
+	p.mu.Lock()
+	// 配置变化后旧连接和描述符缓存都不再可用
+	p.closeUpstreams()
+	p.Configs = grpcConfigs
+	p.AllowRemote = viper.GetBool("auth.grpc.allow_remote")
+	p.RemoteTLS = viper.GetBool("auth.grpc.remote_tls")
+	p.mu.Unlock()
+
+	logger.Log1.
+		WithField("插件名", p.Name).
+		WithField("配置列表", p.ConfigKeys()).
+		WithField("允许远程配置", p.AllowRemote).
+		Info("插件已初始化")
+	return nil
+}
+
+func (p *GRPCPlugin) ConfigType() string {
+	return configv1.PluginGRPC
+}
+
+// Reload 只关闭被修改或删除的 config_key 对应的连接，其余连接继续复用
+func (p *GRPCPlugin) Reload(diff *configv1.PluginDiff) error {
+	var grpcConfigs []GRPCConfig
+	if err := viper.UnmarshalKey("plugins.grpc", &grpcConfigs); err != nil {
+		return fmt.Errorf("解析 gRPC 配置出错: %w", err)
+	}
+
+	p.mu.Lock()
+	for key, u := range p.upstreams {
+		if diff.Affects(u.conf.ConfigKey) {
+			delete(p.upstreams, key)
+			p.retire(u)
+		}
+	}
+	p.Configs = grpcConfigs
+	p.AllowRemote = viper.GetBool("auth.grpc.allow_remote")
+	p.RemoteTLS = viper.GetBool("auth.grpc.remote_tls")
+	p.mu.Unlock()
+
+	logger.Log1.
+		WithField("插件名", p.Name).
+		WithField("配置变化", diff.String()).
+		Info("插件配置已更新")
+	return nil
+}
+
+// ConfigKeys 返回本地配置的 config_key
+func (p *GRPCPlugin) ConfigKeys() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([]string, 0, len(p.Configs))
+	for _, c := range p.Configs {
+		keys = append(keys, c.ConfigKey)
+	}
+	return keys
+}
+
+// TestConnection 检查 config_key 对应的 gRPC 服务能否连接
+func (p *GRPCPlugin) TestConnection(ctx context.Context, configKey string) error {
+	p.mu.Lock()
+	conf := p.findConfigByKey(configKey)
+	p.mu.Unlock()
+	if conf == nil {
+		return fmt.Errorf("未找到配置: %s", configKey)
+	}
+	u, release, err := p.getUpstream(conf)
+	if err != nil {
+		return err
+	}
+	defer release()
+	u.conn.Connect()
+	for {
+		state := u.conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure:
+			return fmt.Errorf("连接 %s 失败", conf.Address)
+		}
+		if !u.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("连接 %s 超时: %w", conf.Address, ctx.Err())
+		}
+	}
+}
+
+func (p *GRPCPlugin) findConfigByKey(key string) *GRPCConfig {
+	for _, config := range p.Configs {
+		if config.ConfigKey == key {
+			logger.Log1.WithField("configKey", config.ConfigKey).Info("找到配置")
+			return &config
+		}
+	}
+	return nil
+}
+
+// getUpstream 获取 (或创建) 配置对应的连接，使用完毕后调用 release；
+// 远程配置的地址由平台下发，连接不缓存，release 时关闭
+func (p *GRPCPlugin) getUpstream(conf *GRPCConfig) (*grpcUpstream, func(), error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cacheKey := conf.ConfigKey + "|" + conf.Address
+	u, ok := p.upstreams[cacheKey]
+	if !ok {
+		creds, err := grpcTransportCredentials(conf)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn, err := grpc.NewClient(conf.Address, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建 gRPC 连接失败: %w", err)
+		}
+
+		u = &grpcUpstream{
+			conf:     *conf,
+			conn:     conn,
+			services: make(map[string]*protoregistry.Files),
+			retired:  conf.ConfigKey == "",
+		}
+		if conf.Protoset != "" {
+			u.files, err = loadProtoset(conf.Protoset)
+			if err != nil {
+				conn.Close()
+				return nil, nil, err
+			}
+		}
+		if !u.retired {
+			p.upstreams[cacheKey] = u
+		}
+		metrics.GRPCConnections.WithLabelValues(metrics.ConfigKey(conf.ConfigKey)).Inc()
+	}
+
+	u.refs++
+	release := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		u.refs--
+		if u.retired && u.refs == 0 {
+			u.close()
+		}
+	}
+	return u, release, nil
+}
+
+// retire 将连接移出缓存后调用，没有进行中的调用时立即关闭，否则由最后一个调用关闭；
+// 调用方需持有 p.mu
+func (p *GRPCPlugin) retire(u *grpcUpstream) {
+	u.retired = true
+	if u.refs == 0 {
+		u.close()
+	}
+}
+
+func (u *grpcUpstream) close() {
+	if err := u.conn.Close(); err != nil {
+		logger.Log1.Warnf("关闭 gRPC 连接失败: %v", err)
+	}
+	metrics.GRPCConnections.WithLabelValues(metrics.ConfigKey(u.conf.ConfigKey)).Dec()
+}
+
+func grpcTransportCredentials(conf *GRPCConfig) (credentials.TransportCredentials, error) {
+	if conf.Plaintext {
+		return insecure.NewCredentials(), nil
+	}
+	tlsConfig := &tls.Config{ServerName: conf.ServerName}
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 文件失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 文件中没有有效的证书: %s", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// loadProtoset 读取 protoc 生成的 FileDescriptorSet
+func loadProtoset(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 protoset 文件失败: %w", err)
+	}
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fds); err != nil {
+		return nil, fmt.Errorf("解析 protoset 文件失败: %w", err)
+	}
+	files, err := protodesc.NewFiles(&fds)
+	if err != nil {
+		return nil, fmt.Errorf("构建 protoset 描述符失败: %w", err)
+	}
+	return files, nil
+}
+
+// resolveFiles 返回包含指定服务的描述符集合，优先使用 protoset，否则使用服务端反射
+func (u *grpcUpstream) resolveFiles(ctx context.Context, service string) (*protoregistry.Files, error) {
+	if u.files != nil {
+		return u.files, nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if files, ok := u.services[service]; ok {
+		return files, nil
+	}
+	files, err := reflectFiles(ctx, u.conn, service)
+	if err != nil {
+		return nil, err
+	}
+	u.services[service] = files
+	return files, nil
+}
+
+// reflectFiles 通过 gRPC 服务端反射获取服务及其依赖的描述符
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("创建反射请求失败: %w", err)
+	}
+	defer stream.CloseSend()
+
+	fdps := make(map[string]*descriptorpb.FileDescriptorProto)
+	request := func(req *reflectionpb.ServerReflectionRequest) error {
+		if err := stream.Send(req); err != nil {
+			return fmt.Errorf("发送反射请求失败: %w", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("接收反射响应失败: %w", err)
+		}
+		if errResp := resp.GetErrorResponse(); errResp != nil {
+			return fmt.Errorf("反射请求出错: %s", errResp.GetErrorMessage())
+		}
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fdp := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, fdp); err != nil {
+				return fmt.Errorf("解析文件描述符失败: %w", err)
+			}
+			fdps[fdp.GetName()] = fdp
+		}
+		return nil
+	}
+
+	err = request(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 补齐缺失的依赖文件
+	for {
+		var missing []string
+		for _, fdp := range fdps {
+			for _, dep := range fdp.GetDependency() {
+				if _, ok := fdps[dep]; !ok {
+					missing = append(missing, dep)
+				}
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		for _, dep := range missing {
+			if _, ok := fdps[dep]; ok {
+				continue
+			}
+			// 标准类型 (google/protobuf/*.proto) 优先使用本地注册的描述符
+			if fd, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				fdps[dep] = protodesc.ToFileDescriptorProto(fd)
+				continue
+			}
+			err := request(&reflectionpb.ServerReflectionRequest{
+				MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := fdps[dep]; !ok {
+				return nil, fmt.Errorf("服务端未返回依赖文件: %s", dep)
+			}
+		}
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	for _, fdp := range fdps {
+		fds.File = append(fds.File, fdp)
+	}
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, fmt.Errorf("构建反射描述符失败: %w", err)
+	}
+	return files, nil
+}
+
+// splitGRPCMethod 解析服务名和方法名，支持 package.Service/Method 写法
+func splitGRPCMethod(service, method string) (string, string, error) {
+	method = strings.TrimPrefix(method, "/")
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		service, method = method[:i], method[i+1:]
+	}
+	if service == "" || method == "" {
+		return "", "", fmt.Errorf("缺少 gRPC 服务名或方法名")
+	}
+	return service, method, nil
+}
+
+func findMethod(files *protoregistry.Files, service, method string) (protoreflect.MethodDescriptor, error) {
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("未找到 gRPC 服务 %s: %w", service, err)
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s 不是 gRPC 服务", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("服务 %s 中未找到方法 %s", service, method)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("暂不支持流式方法: %s/%s", service, method)
+	}
+	return md, nil
+}
+
+// grpcTypeResolver 先从服务描述符中查找类型，再回退到全局注册的类型
+type grpcTypeResolver struct {
+	local *dynamicpb.Types
+}
+
+func (r *grpcTypeResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if mt, err := r.local.FindMessageByName(name); err == nil {
+		return mt, nil
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(name)
+}
+
+func (r *grpcTypeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	if mt, err := r.local.FindMessageByURL(url); err == nil {
+		return mt, nil
+	}
+	return protoregistry.GlobalTypes.FindMessageByURL(url)
+}
+
+func (r *grpcTypeResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if xt, err := r.local.FindExtensionByName(field); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+func (r *grpcTypeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	if xt, err := r.local.FindExtensionByNumber(message, field); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// protoToJSON 将消息转换为可以直接嵌入响应的 JSON 对象
+func protoToJSON(m proto.Message, resolver *grpcTypeResolver) (interface{}, error) {
+	data, err := protojson.MarshalOptions{Resolver: resolver}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var content interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Invoke 执行一次 gRPC 一元调用
+func (p *GRPCPlugin) Invoke(ctx context.Context, conf *GRPCConfig, req *GRPCRequest) (*GRPCResponse, error) {
+	service, method, err := splitGRPCMethod(req.Service, req.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	u, release, err := p.getUpstream(conf)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = conf.Timeout
+	}
+	if timeout <= 0 {
+		timeout = 30000
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+
+	files, err := u.resolveFiles(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	md, err := findMethod(files, service, method)
+	if err != nil {
+		return nil, err
+	}
+	resolver := &grpcTypeResolver{local: dynamicpb.NewTypes(files)}
+
+	// 构造请求消息
+	in := dynamicpb.NewMessage(md.Input())
+	reqJSON := []byte(req.Request)
+	var reqStr string
+	if json.Unmarshal(reqJSON, &reqStr) == nil {
+		// 平台可能把请求体作为字符串下发
+		reqJSON = []byte(reqStr)
+	}
+	if len(strings.TrimSpace(string(reqJSON))) > 0 {
+		if err := (protojson.UnmarshalOptions{Resolver: resolver}).Unmarshal(reqJSON, in); err != nil {
+			return nil, fmt.Errorf("请求消息与 %s 不匹配: %w", md.Input().FullName(), err)
+		}
+	}
+
+	// 合并本地配置和请求中的 metadata，请求中的优先
+	outgoing := metadata.New(conf.Metadata)
+	for k, v := range req.Metadata {
+		outgoing.Set(k, v)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, outgoing)
+
+	out := dynamicpb.NewMessage(md.Output())
+	var header, trailer metadata.MD
+	fullMethod := fmt.Sprintf("/%s/%s", service, method)
+	logger.Log1.WithField("method", fullMethod).WithField("address", conf.Address).Info("发起 gRPC 调用")
+	record := audit.FromContext(ctx)
+	record.SetCommand(conf.ConfigKey, fullMethod)
+	err = u.conn.Invoke(ctx, fullMethod, in, out, grpc.Header(&header), grpc.Trailer(&trailer))
+
+	st := status.Convert(err)
+	record.SetCode(int(st.Code()))
+	response := &GRPCResponse{
+		Code:     int(st.Code()),
+		Status:   st.Code().String(),
+		Message:  st.Message(),
+		Headers:  header,
+		Trailers: trailer,
+	}
+	for _, detail := range st.Proto().GetDetails() {
+		content, err := protoToJSON(detail, resolver)
+		if err != nil {
+			// 无法解析的 detail 原样返回
+			content = map[string]interface{}{
+				"@type": detail.GetTypeUrl(),
+				"value": base64.StdEncoding.EncodeToString(detail.GetValue()),
+			}
+		}
+		response.Details = append(response.Details, content)
+	}
+	if err == nil {
+		response.Response, err = protoToJSON(out, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("序列化响应消息失败: %w", err)
+		}
+	}
+	return response, nil
+}
+
+func (p *GRPCPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
+	data, err := df.GetPluginDataWithType(reflect.TypeOf(GRPCRequest{}))
+	if err != nil {
+		return payload.NewErrorDataFrameResponse(err), err
+	}
+	request := data.(*GRPCRequest)
+
+	p.mu.Lock()
+	allowRemote, remoteTLS := p.AllowRemote, p.RemoteTLS
+	p.mu.Unlock()
+	var conf *GRPCConfig
+	if request.ConfigKey == "" && allowRemote {
+		logger.Log1.WithField("address", request.Address).Info("使用远程配置")
+		conf = &GRPCConfig{Address: request.Address, Plaintext: !remoteTLS}
+	} else {
+		p.mu.Lock()
+		conf = p.findConfigByKey(request.ConfigKey)
+		p.mu.Unlock()
+		if conf == nil {
+			logger.Log1.WithField("configKey", request.ConfigKey).
+				WithField("是否允许远程配置", allowRemote).
+				Error("未找到配置或不允许远程配置")
+			return payload.NewErrorDataFrameResponse(fmt.Errorf("未找到配置或不允许远程配置: %s", request.ConfigKey)), nil
+		}
+	}
+
+	startTime := time.Now()
+	response, err := p.Invoke(ctx, conf, request)
+	if err != nil {
+		logger.Log1.WithField("cost", time.Since(startTime).String()).WithField("error", err).Error("gRPC 调用失败")
+		return payload.NewErrorDataFrameResponse(err), nil
+	}
+	logger.Log1.WithField("cost", time.Since(startTime).String()).WithField("status", response.Status).Info("gRPC 调用结束")
+
+	return v1.NewSuccessDataFrameResponse(response), nil
+}
+
+// closeUpstreams 关闭所有缓存的连接，进行中的调用结束后才会关闭对应的连接；调用方需持有 p.mu
+func (p *GRPCPlugin) closeUpstreams() {
+	for key, u := range p.upstreams {
+		delete(p.upstreams, key)
+		p.retire(u)
+	}
+}
+
+func (p *GRPCPlugin) Close() error {
+	p.mu.Lock()
+	p.closeUpstreams()
+	p.mu.Unlock()
+	logger.Log1.WithField("plugin", p.Name).Info("插件已关闭")
+	return nil
+}
@@ -0,0 +1,158 @@
+package plugins_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+	plugin "github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func startHealthServer(t *testing.T, opts ...grpc.ServerOption) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(opts...)
+	hs := health.NewServer()
+	hs.SetServingStatus("demo", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func newGRPCDataFrame(t *testing.T, data interface{}) *v1.DFWrap {
+	raw, err := json.Marshal(map[string]interface{}{
+		"specVersion": "2.0",
+		"pluginName":  "grpc_plugin",
+		"data":        data,
+	})
+	require.NoError(t, err)
+	return &v1.DFWrap{DataFrame: &payload.DataFrame{Data: string(raw)}}
+}
+
+func decodeGRPCResponse(t *testing.T, resp *payload.DataFrameResponse) *plugin.GRPCResponse {
+	var cr struct {
+		Response plugin.GRPCResponse `json:"response"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resp.Data), &cr))
+	return &cr.Response
+}
+
+func TestGRPCPlugin_Reflection(t *testing.T) {
+	addr := startHealthServer(t)
+	p := plugin.NewGRPCPlugin()
+	p.Configs = []plugin.GRPCConfig{{ConfigKey: "health", Address: addr, Plaintext: true}}
+	defer p.Close()
+
+	resp, err := p.HandleMessage(context.Background(), newGRPCDataFrame(t, map[string]interface{}{
+		"config_key": "health",
+		"method":     "grpc.health.v1.Health/Check",
+		"request":    map[string]interface{}{"service": "demo"},
+		"metadata":   map[string]string{"x-trace": "1"},
+	}))
+	require.NoError(t, err)
+	r := decodeGRPCResponse(t, resp)
+	require.Equal(t, "OK", r.Status)
+	require.Equal(t, map[string]interface{}{"status": "SERVING"}, r.Response)
+
+	// 未知服务返回 gRPC 状态而不是调用失败
+	resp, err = p.HandleMessage(context.Background(), newGRPCDataFrame(t, map[string]interface{}{
+		"config_key": "health",
+		"service":    "grpc.health.v1.Health",
+		"method":     "Check",
+		"request":    `{"service": "missing"}`,
+	}))
+	require.NoError(t, err)
+	r = decodeGRPCResponse(t, resp)
+	require.Equal(t, "NotFound", r.Status)
+	require.Nil(t, r.Response)
+}
+
+func TestGRPCPlugin_Protoset(t *testing.T) {
+	addr := startHealthServer(t)
+
+	fds := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	}
+	data, err := proto.Marshal(fds)
+	require.NoError(t, err)
+	protoset := filepath.Join(t.TempDir(), "health.protoset")
+	require.NoError(t, os.WriteFile(protoset, data, 0o600))
+
+	p := plugin.NewGRPCPlugin()
+	defer p.Close()
+	conf := &plugin.GRPCConfig{ConfigKey: "health", Address: addr, Plaintext: true, Protoset: protoset}
+
+	r, err := p.Invoke(context.Background(), conf, &plugin.GRPCRequest{
+		Method:  "/grpc.health.v1.Health/Check",
+		Request: json.RawMessage(`{}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "OK", r.Status)
+
+	_, err = p.Invoke(context.Background(), conf, &plugin.GRPCRequest{
+		Method: "grpc.health.v1.Health/Watch",
+	})
+	require.ErrorContains(t, err, "流式")
+}
+
+func TestGRPCPlugin_InitDuringCall(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	addr := startHealthServer(t, grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		close(started)
+		<-unblock
+		return handler(ctx, req)
+	}))
+	p := plugin.NewGRPCPlugin()
+	defer p.Close()
+	conf := &plugin.GRPCConfig{ConfigKey: "health", Address: addr, Plaintext: true}
+
+	done := make(chan *plugin.GRPCResponse)
+	go func() {
+		r, err := p.Invoke(context.Background(), conf, &plugin.GRPCRequest{Method: "grpc.health.v1.Health/Check"})
+		require.NoError(t, err)
+		done <- r
+	}()
+
+	// 重新加载配置时进行中的调用继续使用原来的连接
+	<-started
+	require.NoError(t, p.Init())
+	close(unblock)
+	require.Equal(t, "OK", (<-done).Status)
+}
+
+func TestGRPCPlugin_Remote(t *testing.T) {
+	addr := startHealthServer(t)
+	p := plugin.NewGRPCPlugin()
+	p.AllowRemote = true
+	defer p.Close()
+
+	// 远程配置的连接在调用结束后关闭，不会缓存
+	for i := 0; i < 3; i++ {
+		resp, err := p.HandleMessage(context.Background(), newGRPCDataFrame(t, map[string]interface{}{
+			"address": addr,
+			"method":  "grpc.health.v1.Health/Check",
+		}))
+		require.NoError(t, err)
+		require.Equal(t, "OK", decodeGRPCResponse(t, resp).Status)
+	}
+	require.Zero(t, testutil.ToFloat64(metrics.GRPCConnections.WithLabelValues("remote")))
+}
@@ -1,16 +1,17 @@
 package plugins
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"reflect"
 	"strings"
 	"time"
 
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
 	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
 	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
 )
@@ -29,10 +30,59 @@ func NewHTTPPlugin() *HTTPPlugin {
 
 func (p *HTTPPlugin) Init() error {
 	// 初始化插件，例如读取配置
+	if err := v1.LoadHTTPGuard(); err != nil {
+		return err
+	}
+	if err := v1.LoadHTTPDefault(); err != nil {
+		return err
+	}
+	if err := v1.LoadHTTPUpstreams(); err != nil {
+		return err
+	}
 	logger.Log1.WithField("plugin", p.Name).Info("HTTP插件已初始化")
 	return nil
 }
 
+func (p *HTTPPlugin) ConfigType() string {
+	return configv1.PluginHTTP
+}
+
+// Reload 只重建被修改的上游，未变化的上游保留 token、会话和熔断状态
+func (p *HTTPPlugin) Reload(diff *configv1.PluginDiff) error {
+	if diff.AuthChanged {
+		if err := v1.LoadHTTPGuard(); err != nil {
+			return err
+		}
+	}
+	if diff.DefaultChanged {
+		if err := v1.LoadHTTPDefault(); err != nil {
+			return err
+		}
+	}
+	if err := v1.ReloadHTTPUpstreams(diff.Affects); err != nil {
+		return err
+	}
+	logger.Log1.WithField("plugin", p.Name).WithField("配置变化", diff.String()).Info("HTTP插件配置已更新")
+	return nil
+}
+
+// ConfigKeys 返回命名上游的 config_key
+func (p *HTTPPlugin) ConfigKeys() []string {
+	return v1.HTTPUpstreamKeys()
+}
+
+// TestConnection 检查 config_key 对应的上游能否连接
+func (p *HTTPPlugin) TestConnection(ctx context.Context, configKey string) error {
+	if configKey == "" {
+		return fmt.Errorf("未指定 config_key")
+	}
+	u, err := v1.GetHTTPUpstream(configKey)
+	if err != nil {
+		return err
+	}
+	return u.Ping(ctx)
+}
+
 func (p *HTTPPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
 	// 初始化 Data
 	dataVersion := df.GetDataVersion()
@@ -101,12 +151,12 @@ func (p *HTTPPlugin) handleV1(ctx context.Context, df *v1.DFWrap) (*payload.Data
 	}
 
 	// 正常 http 请求
-	resp, err := v1.HandleHTTPRequest(dataModel.Body.HTTPRequest)
+	resp, err := v1.HandleHTTPRequestContext(ctx, dataModel.Body.HTTPRequest)
 	return v1.NewSuccessDataFrameResponseV1(resp), err
 }
 
 // v2 仅仅处理HTTP请求，插件在外面已经被路由
-func (p *HTTPPlugin) handleV2(_ context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
+func (p *HTTPPlugin) handleV2(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
 	// 处理 v2 版本的逻辑
 	logger.Log1.Trace("处理 HTTP 的消息")
 	request, err := df.GetPluginDataWithType(reflect.TypeOf(v1.HTTPRequest{}))
@@ -114,38 +164,82 @@ func (p *HTTPPlugin) handleV2(_ context.Context, df *v1.DFWrap) (*payload.DataFr
 		return nil, err
 	}
 	httpRequest := request.(*v1.HTTPRequest)
-	logger.Log1.WithField("request", httpRequest).Info("收到 HTTP 请求")
+	// 查询参数中可能包含凭证，请求体和请求头只在 debug 级别输出
+	path, _, _ := strings.Cut(httpRequest.URL, "?")
+	logger.Log1.
+		WithField("method", httpRequest.RequestMethod()).
+		WithField("url", path).
+		WithField("configKey", httpRequest.ConfigKey).
+		Info("收到 HTTP 请求")
+	logger.Log1.WithField("request", httpRequest).Debug("HTTP 请求内容")
+
+	// 命名上游
+	upstream, err := v1.GetHTTPUpstream(httpRequest.ConfigKey)
+	if err != nil {
+		return payload.NewErrorDataFrameResponse(err), nil
+	}
+	targetURL, err := upstream.ResolveURL(httpRequest.URL)
+	if err != nil {
+		return payload.NewErrorDataFrameResponse(err), nil
+	}
+
+	// 设置超时
+	if timeout := upstream.Timeout(time.Duration(httpRequest.Timeout) * time.Millisecond); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	// 开始HTTP请求
 	// 创建请求
-	req, err := http.NewRequest(httpRequest.Method, httpRequest.URL, strings.NewReader(httpRequest.Body))
+	body, contentType, err := httpRequest.BuildBody()
+	if err != nil {
+		return payload.NewErrorDataFrameResponse(err), nil
+	}
+	body, transformedType, err := upstream.TransformRequestBody(httpRequest, body)
+	if err != nil {
+		return payload.NewErrorDataFrameResponse(err), nil
+	}
+	if transformedType != "" {
+		contentType = transformedType
+	}
+	req, err := http.NewRequestWithContext(ctx, httpRequest.RequestMethod(), targetURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
+	if err := v1.GetHTTPGuard().CheckURL(req.URL); err != nil {
+		errResp, _ := v1.AsHTTPErrorResponse(err)
+		return v1.NewSuccessDataFrameResponse(errResp), nil
+	}
 
 	// 设置请求头
 	req.Header.Set("Content-Type", httpRequest.ContentType)
 	for key, value := range httpRequest.Headers {
 		req.Header.Set(key, value)
 	}
-
-	// 设置超时的客户端
-	client := &http.Client{
-		Timeout: time.Duration(httpRequest.Timeout) * time.Millisecond,
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
 
 	// 发送请求
-	resp, err := client.Do(req)
+	resp, err := upstream.Do(req)
+	if errResp, ok := v1.AsHTTPErrorResponse(err); ok {
+		return v1.NewSuccessDataFrameResponse(errResp), nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
+	// 读取响应体，超过大小上限时截断
+	body, truncated, err := upstream.ReadResponse(resp, httpRequest)
 	if err != nil {
 		return nil, err
 	}
+	// 执行上游配置的响应转换，截断的响应体无法解析，不做转换
+	if !truncated {
+		body = upstream.TransformResponse(resp.Header, body)
+	}
 
 	// 构造响应
 	headers := make(map[string]string)
@@ -154,15 +248,30 @@ func (p *HTTPPlugin) handleV2(_ context.Context, df *v1.DFWrap) (*payload.DataFr
 	}
 
 	response := &v1.HTTPResponse{
-		StatusCode: resp.StatusCode,
-		Headers:    headers,
+		StatusCode:  resp.StatusCode,
+		Headers:     headers,
+		ContentType: resp.Header.Get("Content-Type"),
+		Truncated:   truncated,
+	}
+	if resp.ContentLength > 0 {
+		response.ContentLength = resp.ContentLength
 	}
 
 	var content map[string]interface{}
-	if err := json.Unmarshal(body, &content); err != nil {
-		response.Content = string(body)
-	} else {
+	var extracted interface{}
+	encoding := v1.ResponseEncoding(resp, httpRequest.ResponseEncoding)
+	parsable := encoding == "" && !truncated
+	switch {
+	case parsable && httpRequest.GraphQL != nil && v1.ApplyGraphQLResponse(response, body):
+		// GraphQL 响应拆分为 data 和 errors，平台可以根据 errors 判断业务错误
+	case parsable && upstream.ExtractsResponse() && json.Unmarshal(body, &extracted) == nil:
+		// 提取结果可能是数组或标量
+		response.Content = extracted
+	case parsable && json.Unmarshal(body, &content) == nil:
 		response.Content = content
+	default:
+		// 二进制内容 (图片、PDF、Excel 等) 以 base64 返回
+		response.Content, response.BodyEncoding = v1.EncodeResponseBody(body, response.ContentType, encoding)
 	}
 
 	return v1.NewSuccessDataFrameResponse(response), nil
@@ -0,0 +1,250 @@
+package plugins_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	plugin "github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func newHTTPDataFrame(t *testing.T, request *v1.HTTPRequest) *v1.DFWrap {
+	raw, err := json.Marshal(map[string]interface{}{
+		"specVersion": "2.0",
+		"pluginName":  "http_plugin",
+		"data":        request,
+	})
+	require.NoError(t, err)
+	return &v1.DFWrap{DataFrame: &payload.DataFrame{Data: string(raw)}}
+}
+
+func callHTTPPlugin(t *testing.T, p *plugin.HTTPPlugin, request *v1.HTTPRequest) *v1.HTTPResponse {
+	resp, err := p.HandleMessage(context.Background(), newHTTPDataFrame(t, request))
+	require.NoError(t, err)
+	var cr struct {
+		Response v1.HTTPResponse `json:"response"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resp.Data), &cr))
+	return &cr.Response
+}
+
+func TestHTTPPlugin_BinaryResponse(t *testing.T) {
+	xlsx := []byte("PK\x03\x04\x14\x00\x06\x00\x08\x00\x00\x00!\x00\xff")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report.xlsx":
+			w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+			w.Write(xlsx)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	defer server.Close()
+
+	p := plugin.NewHTTPPlugin()
+	require.NoError(t, p.Init())
+
+	r := callHTTPPlugin(t, p, &v1.HTTPRequest{Method: "GET", URL: server.URL + "/report.xlsx"})
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Equal(t, v1.BodyEncodingBase64, r.BodyEncoding)
+	require.Equal(t, base64.StdEncoding.EncodeToString(xlsx), r.Content)
+
+	r = callHTTPPlugin(t, p, &v1.HTTPRequest{Method: "GET", URL: server.URL + "/status"})
+	require.Equal(t, map[string]interface{}{"ok": true}, r.Content)
+	require.Empty(t, r.BodyEncoding)
+}
+
+func TestHTTPPlugin_ResponseLimit(t *testing.T) {
+	text := strings.Repeat("0123456789", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/empty" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(text))
+			gz.Close()
+			return
+		}
+		w.Write([]byte(text))
+	}))
+	defer server.Close()
+
+	defer viper.Reset()
+	viper.Set("plugins.http", []map[string]interface{}{
+		{"config_key": "small", "base_url": server.URL, "max_response_size": 100},
+	})
+	p := plugin.NewHTTPPlugin()
+	require.NoError(t, p.Init())
+
+	r := callHTTPPlugin(t, p, &v1.HTTPRequest{ConfigKey: "small", Method: "GET", URL: "/"})
+	require.True(t, r.Truncated)
+	require.EqualValues(t, len(text), r.ContentLength)
+	require.Equal(t, text[:100], r.Content)
+
+	// 请求只能调小上限
+	r = callHTTPPlugin(t, p, &v1.HTTPRequest{ConfigKey: "small", Method: "GET", URL: "/", MaxResponseSize: 1 << 20})
+	require.Equal(t, text[:100], r.Content)
+
+	r = callHTTPPlugin(t, p, &v1.HTTPRequest{Method: "GET", URL: server.URL, ResponseMode: v1.ResponseModePreview, PreviewSize: 10})
+	require.True(t, r.Truncated)
+	require.Equal(t, text[:10], r.Content)
+	require.Equal(t, "text/plain", r.Headers["Content-Type"])
+
+	r = callHTTPPlugin(t, p, &v1.HTTPRequest{Method: "GET", URL: server.URL})
+	require.False(t, r.Truncated)
+	require.Equal(t, text, r.Content)
+
+	// 显式设置 Accept-Encoding 时由插件解压
+	gzipRequest := &v1.HTTPRequest{Method: "GET", URL: server.URL + "/gzip", Headers: map[string]string{"Accept-Encoding": "gzip"}}
+	r = callHTTPPlugin(t, p, gzipRequest)
+	require.Equal(t, text, r.Content)
+	require.Empty(t, r.Headers["Content-Encoding"])
+
+	// 没有响应体时不解压
+	r = callHTTPPlugin(t, p, &v1.HTTPRequest{Method: "GET", URL: server.URL + "/empty", Headers: map[string]string{"Accept-Encoding": "gzip"}})
+	require.Equal(t, http.StatusNoContent, r.StatusCode)
+	require.Empty(t, r.Content)
+	r = callHTTPPlugin(t, p, &v1.HTTPRequest{Method: "HEAD", URL: server.URL + "/gzip", Headers: map[string]string{"Accept-Encoding": "gzip"}})
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Empty(t, r.Content)
+
+	decompress := false
+	gzipRequest.Decompress = &decompress
+	r = callHTTPPlugin(t, p, gzipRequest)
+	require.Equal(t, v1.BodyEncodingBase64, r.BodyEncoding)
+	require.Equal(t, "gzip", r.Headers["Content-Encoding"])
+}
+
+func TestHTTPPlugin_Transform(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Token") != "local" || r.Header.Get("X-Debug") != "" ||
+			r.Header.Get("Content-Type") != "application/xml" ||
+			!strings.Contains(string(body), "<id>42</id>") || !strings.Contains(string(body), "<qty>3</qty>") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(body)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("X-Internal", "secret")
+		w.Write([]byte(`<result><items><sku>A</sku><sku>B</sku></items><status>ok</status></result>`))
+	}))
+	defer server.Close()
+
+	request := map[string]interface{}{
+		"headers":       map[string]interface{}{"set": map[string]string{"X-Token": "local"}, "remove": []string{"X-Debug"}},
+		"body_template": `{"order": {"id": "{{ .vars.orderId }}", "qty": {{ .body.qty }}}}`,
+		"json_to_xml":   true,
+	}
+	defer viper.Reset()
+	viper.Set("plugins.http", []map[string]interface{}{
+		{"config_key": "erp", "base_url": server.URL, "transform": map[string]interface{}{
+			"request":  request,
+			"response": map[string]interface{}{"xml_to_json": true, "extract": "result.items.sku", "headers": map[string]interface{}{"remove": []string{"X-Internal"}}},
+		}},
+		{"config_key": "erp_raw", "base_url": server.URL, "transform": map[string]interface{}{
+			"request":  request,
+			"response": map[string]interface{}{"xml_to_json": true},
+		}},
+	})
+	p := plugin.NewHTTPPlugin()
+	require.NoError(t, p.Init())
+
+	call := func(configKey string) *v1.HTTPResponse {
+		return callHTTPPlugin(t, p, &v1.HTTPRequest{
+			ConfigKey: configKey,
+			Method:    "POST",
+			URL:       "/orders",
+			Headers:   map[string]string{"X-Debug": "1"},
+			Body:      `{"qty": 3}`,
+			Variables: map[string]interface{}{"orderId": 42},
+		})
+	}
+
+	r := call("erp")
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Equal(t, []interface{}{"A", "B"}, r.Content)
+	require.Empty(t, r.Headers["X-Internal"])
+
+	r = call("erp_raw")
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Equal(t, "application/json", r.ContentType)
+	require.Equal(t, map[string]interface{}{
+		"result": map[string]interface{}{
+			"items":  map[string]interface{}{"sku": []interface{}{"A", "B"}},
+			"status": "ok",
+		},
+	}, r.Content)
+	require.Equal(t, "secret", r.Headers["X-Internal"])
+
+	viper.Set("plugins.http", []map[string]interface{}{
+		{"config_key": "bad", "base_url": server.URL, "transform": map[string]interface{}{
+			"response": map[string]interface{}{"extract": "result.[["},
+		}},
+	})
+	require.Error(t, p.Init())
+}
+
+func TestHTTPPlugin_GraphQL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q v1.GraphQLRequest
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" || json.NewDecoder(r.Body).Decode(&q) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if q.OperationName == "Broken" {
+			w.Write([]byte(`{"data": null, "errors": [{"message": "field not found", "locations": [{"line": 1, "column": 9}], "path": ["user", "nickname"]}]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"user": {"id": %q, "name": "Ding"}}}`, q.Variables["id"])
+	}))
+	defer server.Close()
+
+	defer viper.Reset()
+	viper.Set("plugins.http", []map[string]interface{}{
+		{"config_key": "tools", "base_url": server.URL + "/graphql"},
+	})
+	p := plugin.NewHTTPPlugin()
+	require.NoError(t, p.Init())
+
+	r := callHTTPPlugin(t, p, &v1.HTTPRequest{ConfigKey: "tools", Method: "GET", GraphQL: &v1.GraphQLRequest{
+		Query:     "query User($id: ID!) { user(id: $id) { id name } }",
+		Variables: map[string]interface{}{"id": "u1"},
+	}})
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Equal(t, map[string]interface{}{"user": map[string]interface{}{"id": "u1", "name": "Ding"}}, r.Data)
+	require.Empty(t, r.Errors)
+
+	// GraphQL 错误与 HTTP 200 一起返回
+	r = callHTTPPlugin(t, p, &v1.HTTPRequest{ConfigKey: "tools", GraphQL: &v1.GraphQLRequest{
+		Query:         "query Broken { user { nickname } }",
+		OperationName: "Broken",
+	}})
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Nil(t, r.Data)
+	require.Len(t, r.Errors, 1)
+	require.Equal(t, "field not found", r.Errors[0].Message)
+	require.Equal(t, []v1.GraphQLLocation{{Line: 1, Column: 9}}, r.Errors[0].Locations)
+
+	resp, err := p.HandleMessage(context.Background(), newHTTPDataFrame(t, &v1.HTTPRequest{ConfigKey: "tools", GraphQL: &v1.GraphQLRequest{}}))
+	require.NoError(t, err)
+	require.Equal(t, payload.DataFrameResponseStatusCodeKInternalError, resp.Code)
+}
@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
 	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
 	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
 	"github.com/spf13/viper"
@@ -18,6 +20,8 @@ type PGSQLPlugin struct {
 	Name        string
 	AllowRemote bool
 	Configs     []Body
+	// 保护 Configs 和 AllowRemote，配置热加载时会被替换
+	mu sync.RWMutex
 }
 
 func (p *PGSQLPlugin) GetConnection(body *Body) (*sql.DB, error) {
@@ -28,9 +32,20 @@ func (p *PGSQLPlugin) GetConnection(body *Body) (*sql.DB, error) {
 }
 
 // doSQLExecute 执行SQL查询
-func (p *PGSQLPlugin) DoSQLExecute(body *Body) (qr *QueryResult) {
+func (p *PGSQLPlugin) DoSQLExecute(body *Body) *QueryResult {
+	return p.DoSQLExecuteContext(context.Background(), body)
+}
+
+// DoSQLExecuteContext 执行SQL查询，ctx 取消时中止查询
+func (p *PGSQLPlugin) DoSQLExecuteContext(ctx context.Context, body *Body) (qr *QueryResult) {
 	startTime := time.Now()
+	p.mu.RLock()
+	local := findSQLConfig(p.Configs, body.ConfigKey)
+	gen := sqlPoolGen(p.Name)
+	p.mu.RUnlock()
+	ctx, done := trackSQL(ctx, p.Name, "postgresql", body, local != nil)
 	defer func() {
+		done(qr)
 		if qr != nil && qr.Message != "success" {
 			logger.Log1.WithField("cost", time.Since(startTime).String()).Errorf("SQL查询结束")
 		} else {
@@ -39,7 +54,7 @@ func (p *PGSQLPlugin) DoSQLExecute(body *Body) (qr *QueryResult) {
 	}()
 
 	// 获取数据库连接
-	db, err := p.GetConnection(body)
+	db, release, err := getSQLConnection(p, p.Name, gen, local, body)
 	if err != nil {
 		return &QueryResult{
 			Result:  nil,
@@ -47,10 +62,10 @@ func (p *PGSQLPlugin) DoSQLExecute(body *Body) (qr *QueryResult) {
 			Message: err.Error(),
 		}
 	}
-	defer db.Close()
+	defer release()
 
 	logger.Log1.WithField("sql", body.SQL).Info("执行SQL")
-	rows, err := db.Query(body.SQL)
+	rows, err := db.QueryContext(ctx, body.SQL)
 	if err != nil {
 		return &QueryResult{
 			Result:  nil,
@@ -122,7 +137,7 @@ func (p *PGSQLPlugin) DoSQLExecute(body *Body) (qr *QueryResult) {
 func (p *PGSQLPlugin) findConfigByKey(key string) *Body {
 	for _, config := range p.Configs {
 		if config.ConfigKey == key {
-			logger.Log1.WithField("config", config).Info("找到配置")
+			logger.Log1.WithField("configKey", config.ConfigKey).Info("找到配置")
 			return &config
 		}
 	}
@@ -135,23 +150,65 @@ func NewPGSQLPlugin() *PGSQLPlugin {
 	}
 }
 
+func (p *PGSQLPlugin) ConfigType() string {
+	return configv1.PluginPGSQL
+}
+
+// ConfigKeys 返回本地配置的 config_key
+func (p *PGSQLPlugin) ConfigKeys() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return sqlConfigKeys(p.Configs)
+}
+
+// TestConnection 连接 config_key 对应的数据库并执行 SELECT 1
+func (p *PGSQLPlugin) TestConnection(ctx context.Context, configKey string) error {
+	p.mu.RLock()
+	conf := p.findConfigByKey(configKey)
+	p.mu.RUnlock()
+	if conf == nil {
+		return fmt.Errorf("未找到配置: %s", configKey)
+	}
+	return probeSQL(ctx, p, conf, "SELECT 1")
+}
+
 func (p *PGSQLPlugin) Init() error {
+	return p.load(nil)
+}
+
+// Reload 只关闭被修改或删除的 config_key 对应的连接池，未变化的连接池继续复用
+func (p *PGSQLPlugin) Reload(diff *configv1.PluginDiff) error {
+	return p.load(diff)
+}
+
+// load 读取并替换配置，diff 为 nil (初始化) 时关闭全部连接池
+func (p *PGSQLPlugin) load(diff *configv1.PluginDiff) error {
 	// 定义一个变量来存储 SQL 配置
 	var sqlConfigs []Body
 
 	// 解析 SQL 配置
 	if err := viper.UnmarshalKey("plugins.pgsql", &sqlConfigs); err != nil {
-		logger.Log1.Fatalf("解析 MSSQL 配置出错: %v", err)
+		return fmt.Errorf("解析 PGSQL 配置出错: %w", err)
 	}
+	allowRemote := viper.GetBool("auth.pgsql.allow_remote")
 
+	p.mu.Lock()
 	p.Configs = sqlConfigs
+	p.AllowRemote = allowRemote
+	reloadSQLPools(p.Name, diff)
+	p.mu.Unlock()
 
-	p.AllowRemote = viper.GetBool("auth.pgsql.allow_remote")
-
+	if diff != nil {
+		logger.Log1.
+			WithField("插件名", p.Name).
+			WithField("配置变化", diff.String()).
+			Info("插件配置已更新")
+		return nil
+	}
 	logger.Log1.
 		WithField("插件名", p.Name).
-		WithField("配置列表", p.Configs).
-		WithField("允许远程配置", p.AllowRemote).
+		WithField("配置列表", sqlConfigKeys(sqlConfigs)).
+		WithField("允许远程配置", allowRemote).
 		Info("插件已初始化")
 	return nil
 }
@@ -165,13 +222,22 @@ func (p *PGSQLPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payloa
 	}
 
 	remoteConf := data.(*Body)
-	if remoteConf.ConfigKey == "" && p.AllowRemote {
-		logger.Log1.WithField("config", remoteConf).Info("使用远程配置")
+	p.mu.RLock()
+	allowRemote := p.AllowRemote
+	p.mu.RUnlock()
+	if remoteConf.ConfigKey == "" && allowRemote {
+		logger.Log1.
+			WithField("host", remoteConf.Host).
+			WithField("address", remoteConf.Address).
+			WithField("database", remoteConf.Database).
+			Info("使用远程配置")
 	} else {
+		p.mu.RLock()
 		localConf := p.findConfigByKey(remoteConf.ConfigKey)
+		p.mu.RUnlock()
 		if localConf == nil {
 			logger.Log1.WithField("configKey", remoteConf.ConfigKey).
-				WithField("是否允许远程配置", p.AllowRemote).
+				WithField("是否允许远程配置", allowRemote).
 				Error("未找到配置或不允许远程配置")
 			return payload.NewErrorDataFrameResponse(fmt.Errorf("未找到配置或不允许远程配置: %s", remoteConf.ConfigKey)), nil
 		}
@@ -179,7 +245,7 @@ func (p *PGSQLPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payloa
 	}
 
 	callBackResponse := &CallbackResponse{
-		Response: p.DoSQLExecute(remoteConf),
+		Response: p.DoSQLExecuteContext(ctx, remoteConf),
 	}
 
 	resp := payload.NewSuccessDataFrameResponse()
@@ -191,6 +257,7 @@ func (p *PGSQLPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payloa
 
 func (p *PGSQLPlugin) Close() error {
 	// 关闭插件
+	closeSQLPools(p.Name)
 	logger.Log1.WithField("plugin", p.Name).Info("插件已关闭")
 	return nil
 }
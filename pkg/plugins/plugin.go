@@ -3,11 +3,20 @@ package plugins
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	"github.com/open-dingtalk/ipaas-agent/pkg/audit"
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
 	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
 	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type Plugin interface {
@@ -24,87 +33,205 @@ type CallbackResponse v1.CallbackResponse
 type PluginManager struct {
 	plugins map[string]Plugin
 	mu      sync.RWMutex
+
+	// 插件初始化状态和最近一次错误
+	statusMu sync.Mutex
+	status   map[string]*PluginStatus
+	loaded   atomic.Bool
+}
+
+// PluginStatus 插件的初始化状态及最近一次错误 (初始化或处理消息)
+type PluginStatus struct {
+	Initialized bool       `json:"initialized"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
 }
 
 func NewPluginManager() *PluginManager {
 	return &PluginManager{
 		plugins: make(map[string]Plugin),
+		status:  make(map[string]*PluginStatus),
+	}
+}
+
+// recordInit 记录插件初始化 (或重新加载) 的结果
+func (pm *PluginManager) recordInit(name string, err error) {
+	pm.statusMu.Lock()
+	defer pm.statusMu.Unlock()
+	status := pm.statusOf(name)
+	status.Initialized = err == nil
+	if err != nil {
+		status.setError(err)
+	}
+}
+
+// recordError 记录插件处理消息时返回的错误
+func (pm *PluginManager) recordError(name string, err error) {
+	pm.statusMu.Lock()
+	defer pm.statusMu.Unlock()
+	pm.statusOf(name).setError(err)
+}
+
+// statusOf 调用方需持有 statusMu
+func (pm *PluginManager) statusOf(name string) *PluginStatus {
+	status, ok := pm.status[name]
+	if !ok {
+		status = &PluginStatus{}
+		pm.status[name] = status
 	}
+	return status
+}
+
+func (s *PluginStatus) setError(err error) {
+	now := time.Now()
+	s.LastError = logger.RedactString(err.Error())
+	s.LastErrorAt = &now
+}
+
+// Loaded 是否已完成 LoadPlugins
+func (pm *PluginManager) Loaded() bool {
+	return pm.loaded.Load()
 }
 
-func (pm *PluginManager) ReloadConfig() error {
-	// Reinitialize all plugins
+// Status 返回所有已注册插件的状态
+func (pm *PluginManager) Status() map[string]PluginStatus {
 	pm.mu.RLock()
-	for name, plugin := range pm.plugins {
-		if err := plugin.Init(); err != nil {
-			logger.Log1.Errorf("重新初始化插件 %s 失败: %v", name, err)
+	defer pm.mu.RUnlock()
+	pm.statusMu.Lock()
+	defer pm.statusMu.Unlock()
+	result := make(map[string]PluginStatus, len(pm.plugins))
+	for name := range pm.plugins {
+		if status, ok := pm.status[name]; ok {
+			result[name] = *status
+		} else {
+			result[name] = PluginStatus{}
 		}
 	}
-	pm.mu.RUnlock()
+	return result
+}
 
-	return nil
+// ConfigTypePlugin 声明插件使用的配置类型 (plugins.<type>、auth.<type>)，
+// 配置热加载时只有对应部分变化的插件才会重新加载
+type ConfigTypePlugin interface {
+	ConfigType() string
 }
 
-func (pm *PluginManager) LoadPlugins() error {
-	// 加载插件，可以使用反射或手动注册
+// ReloadablePlugin 可以按配置变化增量更新的插件，未实现时重新调用 Init
+type ReloadablePlugin interface {
+	Reload(diff *configv1.PluginDiff) error
+}
 
-	// 1. http 插件
-	httpPlugin := NewHTTPPlugin()
-	err := httpPlugin.Init()
-	httpPlugin.pm = pm
-	if err != nil {
-		logger.Log1.Errorf("初始化 HTTP 插件失败: %v", err)
-	}
-	pm.RegisterPlugin(httpPlugin.Name, httpPlugin)
+// ConnectionTester 可以检查本地配置能否连接的插件
+type ConnectionTester interface {
+	// ConfigKeys 本地配置的 config_key 列表
+	ConfigKeys() []string
+	TestConnection(ctx context.Context, configKey string) error
+}
 
-	// 2. 版本管理插件
-	versionPlugin := NewVersionPlugin()
-	err = versionPlugin.Init()
-	if err != nil {
-		logger.Log1.Errorf("初始化 Version 插件失败: %v", err)
-	}
-	pm.RegisterPlugin(versionPlugin.Name, versionPlugin)
+// ConnectionTarget 一条可以检查连接的本地配置
+type ConnectionTarget struct {
+	Type      string
+	ConfigKey string
+}
 
-	// 3. 旧 mysql 插件
-	ProxyMySQLPlugin := NewProxyMySQLPlugin()
-	err = ProxyMySQLPlugin.Init()
-	if err != nil {
-		logger.Log1.Errorf("初始化 ProxyMySQL 插件失败: %v", err)
+func (pm *PluginManager) ReloadConfig(diff *configv1.ConfigDiff) error {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	for name, plugin := range pm.plugins {
+		var pluginDiff *configv1.PluginDiff
+		if p, ok := plugin.(ConfigTypePlugin); ok {
+			if pluginDiff = diff.Plugin(p.ConfigType()); pluginDiff.Empty() {
+				continue
+			}
+		}
+		var err error
+		if p, ok := plugin.(ReloadablePlugin); ok && pluginDiff != nil {
+			err = p.Reload(pluginDiff)
+		} else {
+			err = plugin.Init()
+		}
+		pm.recordInit(name, err)
+		if err != nil {
+			logger.Log1.Errorf("重新初始化插件 %s 失败: %v", name, err)
+		}
 	}
-	pm.RegisterPlugin(ProxyMySQLPlugin.Name, ProxyMySQLPlugin)
+	return nil
+}
 
-	// 4. 新 mysql 插件
-	mysqlPlugin := NewMySQLPlugin()
-	err = mysqlPlugin.Init()
-	if err != nil {
-		logger.Log1.Errorf("初始化 MySQL 插件失败: %v", err)
+// TestConnection 使用 pluginType (mysql、http 等) 类型的插件检查 config_key 对应的配置能否连接
+func (pm *PluginManager) TestConnection(ctx context.Context, pluginType, configKey string) error {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	for _, plugin := range pm.plugins {
+		if p, ok := plugin.(ConfigTypePlugin); !ok || p.ConfigType() != pluginType {
+			continue
+		}
+		if tester, ok := plugin.(ConnectionTester); ok {
+			return tester.TestConnection(ctx, configKey)
+		}
 	}
-	pm.RegisterPlugin(mysqlPlugin.Name, mysqlPlugin)
+	return fmt.Errorf("插件 %s 不支持连接测试", pluginType)
+}
 
-	// 5. ms sql 插件
-	mssqlPlugin := NewMSSQLPlugin()
-	err = mssqlPlugin.Init()
-	if err != nil {
-		logger.Log1.Errorf("初始化 MSSQL 插件失败: %v", err)
+// ConnectionTargets 返回所有插件中可以检查连接的本地配置，按插件类型排序
+func (pm *PluginManager) ConnectionTargets() []ConnectionTarget {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	var targets []ConnectionTarget
+	for _, plugin := range pm.plugins {
+		p, ok := plugin.(ConfigTypePlugin)
+		if !ok {
+			continue
+		}
+		if tester, ok := plugin.(ConnectionTester); ok {
+			for _, key := range tester.ConfigKeys() {
+				targets = append(targets, ConnectionTarget{Type: p.ConfigType(), ConfigKey: key})
+			}
+		}
 	}
-	pm.RegisterPlugin(mssqlPlugin.Name, mssqlPlugin)
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].Type < targets[j].Type
+	})
+	return targets
+}
 
-	// 5. pg sql 插件
+func (pm *PluginManager) LoadPlugins() error {
+	// 加载插件，可以使用反射或手动注册
+	httpPlugin := NewHTTPPlugin()
+	httpPlugin.pm = pm
+	versionPlugin := NewVersionPlugin()
+	proxyMySQLPlugin := NewProxyMySQLPlugin()
+	mysqlPlugin := NewMySQLPlugin()
+	mssqlPlugin := NewMSSQLPlugin()
 	pgsqlPlugin := NewPGSQLPlugin()
-	err = pgsqlPlugin.Init()
-	if err != nil {
-		logger.Log1.Errorf("初始化 PGSQL 插件失败: %v", err)
-	}
-	pm.RegisterPlugin(pgsqlPlugin.Name, pgsqlPlugin)
-
-	// 6. oracle db 插件
 	oracleDBPlugin := NewOracleDBPlugin()
-	err = oracleDBPlugin.Init()
-	if err != nil {
-		logger.Log1.Errorf("初始化 OracleDB 插件失败: %v", err)
-	}
-	pm.RegisterPlugin(oracleDBPlugin.Name, oracleDBPlugin)
+	grpcPlugin := NewGRPCPlugin()
 
+	plugins := []struct {
+		label  string
+		name   string
+		plugin Plugin
+	}{
+		{"HTTP", httpPlugin.Name, httpPlugin},
+		// 版本管理插件
+		{"Version", versionPlugin.Name, versionPlugin},
+		// 旧 mysql 插件
+		{"ProxyMySQL", proxyMySQLPlugin.Name, proxyMySQLPlugin},
+		{"MySQL", mysqlPlugin.Name, mysqlPlugin},
+		{"MSSQL", mssqlPlugin.Name, mssqlPlugin},
+		{"PGSQL", pgsqlPlugin.Name, pgsqlPlugin},
+		{"OracleDB", oracleDBPlugin.Name, oracleDBPlugin},
+		{"gRPC", grpcPlugin.Name, grpcPlugin},
+	}
+	for _, p := range plugins {
+		err := p.plugin.Init()
+		pm.recordInit(p.name, err)
+		if err != nil {
+			logger.Log1.Errorf("初始化 %s 插件失败: %v", p.label, err)
+		}
+		pm.RegisterPlugin(p.name, p.plugin)
+	}
+	pm.loaded.Store(true)
 	return nil
 }
 
@@ -115,20 +242,64 @@ func (pm *PluginManager) RegisterPlugin(name string, plugin Plugin) {
 	logger.Log1.WithField("plugin", name).Info("插件已注册")
 }
 
-func (pm *PluginManager) HandleMessage(ctx context.Context, df *payload.DataFrame) (*payload.DataFrameResponse, error) {
+func (pm *PluginManager) HandleMessage(ctx context.Context, df *payload.DataFrame) (response *payload.DataFrameResponse, err error) {
 	// 根据消息类型选择对应的插件处理
 	dfWrap := &v1.DFWrap{
 		DataFrame: df,
 	}
 	pluginName := dfWrap.GetPluginName()
+	specVersion := dfWrap.GetDataVersion()
+	ctx, span := tracing.Start(ctx, "PluginManager.HandleMessage")
+	span.SetAttributes(
+		attribute.String("ipaas.plugin", pluginName),
+		attribute.String("ipaas.spec_version", specVersion),
+	)
+	// 插件执行时向 record 补充 SQL、请求地址等操作内容
+	record := audit.NewRecord(df.GetMessageId(), pluginName)
+	headers := dfWrap.GetHeaders()
+	record.SetConnector(headers.ConnectorCorpId, headers.ConnectorId, headers.ActionId)
+	ctx = audit.WithRecord(ctx, record)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	pm.mu.RLock()
 	plugin, exists := pm.plugins[pluginName]
 	pm.mu.RUnlock()
 	if !exists {
-		return nil, fmt.Errorf("未找到对应的插件: %s", pluginName)
+		err = fmt.Errorf("未找到对应的插件: %s", pluginName)
+		record.Finish(err)
+		return nil, err
 	}
 	// event.NewSuccessResponse()
-	return plugin.HandleMessage(ctx, dfWrap)
+	startTime := time.Now()
+	response, err = pm.execute(ctx, pluginName, plugin, dfWrap)
+	metrics.CallbackDuration.WithLabelValues(pluginName, specVersion).Observe(time.Since(startTime).Seconds())
+	result := metrics.Result(err)
+	auditErr := err
+	if response != nil && response.Code != payload.DataFrameResponseStatusCodeKOK {
+		result = "error"
+		if auditErr == nil {
+			auditErr = fmt.Errorf("响应码 %d: %s", response.Code, response.Message)
+		}
+	}
+	metrics.CallbacksTotal.WithLabelValues(pluginName, specVersion, result).Inc()
+	record.Finish(auditErr)
+	if err != nil {
+		pm.recordError(pluginName, err)
+	}
+	return response, err
+}
+
+// execute 在插件执行的 span 中处理消息，插件返回错误响应时同样标记为失败
+func (pm *PluginManager) execute(ctx context.Context, pluginName string, plugin Plugin, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
+	ctx, span := tracing.Start(ctx, pluginName+".HandleMessage")
+	response, err := plugin.HandleMessage(ctx, df)
+	if err == nil && response != nil && response.Code != payload.DataFrameResponseStatusCodeKOK {
+		span.SetStatus(codes.Error, response.Message)
+	}
+	tracing.End(span, err)
+	return response, err
 }
 
 func (pm *PluginManager) CloseAll() {
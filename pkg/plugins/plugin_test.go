@@ -0,0 +1,211 @@
+package plugins_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	"github.com/open-dingtalk/ipaas-agent/pkg/audit"
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+	plugin "github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePlugin struct {
+	configType string
+	inits      int
+	reloads    []*configv1.PluginDiff
+}
+
+func (p *fakePlugin) Init() error {
+	p.inits++
+	return nil
+}
+
+func (p *fakePlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
+	return nil, nil
+}
+
+func (p *fakePlugin) Close() error { return nil }
+
+func (p *fakePlugin) ConfigType() string { return p.configType }
+
+type reloadablePlugin struct {
+	fakePlugin
+}
+
+func (p *reloadablePlugin) Reload(diff *configv1.PluginDiff) error {
+	p.reloads = append(p.reloads, diff)
+	return nil
+}
+
+// 没有声明配置类型的插件
+type plainPlugin struct {
+	inits int
+}
+
+func (p *plainPlugin) Init() error {
+	p.inits++
+	return nil
+}
+
+func (p *plainPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
+	return nil, nil
+}
+
+func (p *plainPlugin) Close() error { return nil }
+
+func TestPluginManager_ReloadConfig(t *testing.T) {
+	mysql := &fakePlugin{configType: configv1.PluginMySQL}
+	mssql := &fakePlugin{configType: configv1.PluginMSSQL}
+	upstreams := &reloadablePlugin{fakePlugin{configType: configv1.PluginHTTP}}
+	plain := &plainPlugin{}
+
+	pm := plugin.NewPluginManager()
+	pm.RegisterPlugin("mysql", mysql)
+	pm.RegisterPlugin("mssql", mssql)
+	pm.RegisterPlugin("http", upstreams)
+	pm.RegisterPlugin("plain", plain)
+
+	httpDiff := &configv1.PluginDiff{Changed: []string{"erp"}}
+	require.NoError(t, pm.ReloadConfig(&configv1.ConfigDiff{
+		Plugins: map[string]*configv1.PluginDiff{
+			configv1.PluginMySQL: {Added: []string{"orders"}},
+			configv1.PluginHTTP:  httpDiff,
+		},
+	}))
+
+	require.Equal(t, 1, mysql.inits)
+	require.Equal(t, 0, mssql.inits)
+	require.Equal(t, 0, upstreams.inits)
+	require.Equal(t, []*configv1.PluginDiff{httpDiff}, upstreams.reloads)
+	require.Equal(t, 1, plain.inits)
+}
+
+// failingPlugin 处理消息时返回错误
+type failingPlugin struct {
+	plainPlugin
+}
+
+func (p *failingPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
+	return nil, errors.New("backend unavailable")
+}
+
+func TestPluginManager_HandleMessage(t *testing.T) {
+	message := func(pluginName string) *payload.DataFrame {
+		return &payload.DataFrame{
+			Data: `{"specVersion":"2.0","pluginName":"` + pluginName + `"}`,
+		}
+	}
+	pm := plugin.NewPluginManager()
+	pm.RegisterPlugin("ok_plugin", &plainPlugin{})
+	pm.RegisterPlugin("failing_plugin", &failingPlugin{})
+
+	_, err := pm.HandleMessage(context.Background(), message("ok_plugin"))
+	require.NoError(t, err)
+	_, err = pm.HandleMessage(context.Background(), message("failing_plugin"))
+	require.Error(t, err)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.CallbacksTotal.WithLabelValues("ok_plugin", "2.0", "success")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.CallbacksTotal.WithLabelValues("failing_plugin", "2.0", "error")))
+
+	status := pm.Status()
+	require.Empty(t, status["ok_plugin"].LastError)
+	require.Equal(t, "backend unavailable", status["failing_plugin"].LastError)
+	require.NotNil(t, status["failing_plugin"].LastErrorAt)
+}
+
+// queryPlugin 模拟执行 SQL 的插件
+type queryPlugin struct {
+	plainPlugin
+}
+
+func (p *queryPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
+	record := audit.FromContext(ctx)
+	record.SetSQL("orders", "SELECT * FROM orders WHERE id = 42")
+	record.SetRows(3)
+	return v1.NewSuccessDataFrameResponse(nil), nil
+}
+
+func TestPluginManager_HandleMessage_Audit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, audit.Setup(audit.Options{File: path}))
+	defer audit.Close()
+
+	pm := plugin.NewPluginManager()
+	pm.RegisterPlugin("query_plugin", &queryPlugin{})
+	df := &payload.DataFrame{
+		Headers: payload.DataFrameHeader{payload.DataFrameHeaderKMessageId: "msg-1"},
+		Data:    `{"specVersion":"2.0","pluginName":"query_plugin","headers":{"connectorCorpId":"corp","connectorId":"connector","actionId":"action"}}`,
+	}
+	_, err := pm.HandleMessage(context.Background(), df)
+	require.NoError(t, err)
+	_, err = pm.HandleMessage(context.Background(), &payload.DataFrame{
+		Data: `{"specVersion":"2.0","pluginName":"missing_plugin"}`,
+	})
+	require.Error(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+	var entry audit.Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, "msg-1", entry.MessageID)
+	require.Equal(t, "corp", entry.ConnectorCorpID)
+	require.Equal(t, "connector", entry.ConnectorID)
+	require.Equal(t, "action", entry.ActionID)
+	require.Equal(t, "query_plugin", entry.Plugin)
+	require.Equal(t, "orders", entry.ConfigKey)
+	require.Equal(t, "SELECT * FROM orders WHERE id = ?", entry.SQL)
+	require.Equal(t, 3, *entry.Rows)
+	require.Equal(t, "success", entry.Status)
+
+	entry = audit.Entry{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	require.Equal(t, "missing_plugin", entry.Plugin)
+	require.Equal(t, "error", entry.Status)
+	require.Contains(t, entry.Error, "未找到对应的插件")
+}
+
+func TestPluginManager_TestConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodHead, r.Method)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	defer viper.Reset()
+	viper.Set("plugins.http", []map[string]interface{}{
+		{"config_key": "erp", "base_url": server.URL},
+		{"config_key": "down", "base_url": "http://127.0.0.1:1"},
+	})
+	pm := plugin.NewPluginManager()
+	httpPlugin := plugin.NewHTTPPlugin()
+	require.NoError(t, httpPlugin.Init())
+	pm.RegisterPlugin(httpPlugin.Name, httpPlugin)
+	pm.RegisterPlugin("mysql", &fakePlugin{configType: configv1.PluginMySQL})
+
+	require.Equal(t, []plugin.ConnectionTarget{
+		{Type: configv1.PluginHTTP, ConfigKey: "down"},
+		{Type: configv1.PluginHTTP, ConfigKey: "erp"},
+	}, pm.ConnectionTargets())
+
+	ctx := context.Background()
+	// 收到任意响应即可连接
+	require.NoError(t, pm.TestConnection(ctx, configv1.PluginHTTP, "erp"))
+	require.Error(t, pm.TestConnection(ctx, configv1.PluginHTTP, "down"))
+	require.ErrorContains(t, pm.TestConnection(ctx, configv1.PluginHTTP, "missing"), "missing")
+	require.ErrorContains(t, pm.TestConnection(ctx, configv1.PluginMySQL, "db"), "不支持连接测试")
+}
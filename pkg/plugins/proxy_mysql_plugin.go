@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+	"github.com/open-dingtalk/ipaas-agent/pkg/audit"
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
 	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
 	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
 )
@@ -19,6 +21,10 @@ func NewProxyMySQLPlugin() *ProxyMySQLPlugin {
 	}
 }
 
+func (p *ProxyMySQLPlugin) ConfigType() string {
+	return configv1.PluginMySQL
+}
+
 func (p *ProxyMySQLPlugin) Init() error {
 	// 初始化插件，例如读取配置
 	logger.Log1.
@@ -28,10 +34,18 @@ func (p *ProxyMySQLPlugin) Init() error {
 }
 
 func (p *ProxyMySQLPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
-	res, err := v1.HandleMySQLProxyRequest(df.GetDataModelV1())
+	dataModel := df.GetDataModelV1()
+	record := audit.FromContext(ctx)
+	if dataModel != nil {
+		record.SetSQL(dataModel.Body.ConfigKey, dataModel.Body.ConfigParams["sql"])
+	}
+	res, err := v1.HandleMySQLProxyRequest(dataModel)
 	if err != nil {
 		return payload.NewErrorDataFrameResponse(err), err
 	}
+	if rows, ok := res.([]map[string]interface{}); ok {
+		record.SetRows(len(rows))
+	}
 	return v1.NewSuccessDataFrameResponseV1(res), nil
 }
 
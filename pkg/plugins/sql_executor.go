@@ -1,14 +1,110 @@
 package plugins
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/audit"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+	"github.com/open-dingtalk/ipaas-agent/pkg/tracing"
 )
 
 type SQLExecutor interface {
 	GetConnection(body *Body) (*sql.DB, error)
 	DoSQLExecute(body *Body) *QueryResult
+	DoSQLExecuteContext(ctx context.Context, body *Body) *QueryResult
+}
+
+// probeSQL 使用配置建立连接并执行 query (例如 SELECT 1)，检查数据库是否可用
+func probeSQL(ctx context.Context, executor SQLExecutor, conf *Body, query string) error {
+	db, err := executor.GetConnection(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result interface{}
+	return db.QueryRowContext(ctx, query).Scan(&result)
+}
+
+// trackSQL 记录正在执行的查询并开始 SQL 的 span，返回的函数在查询结束时记录耗时和返回行数；
+// local 为 false 时指标中的 config_key 统一记为 remote
+func trackSQL(ctx context.Context, plugin, dbSystem string, body *Body, local bool) (context.Context, func(qr *QueryResult)) {
+	configKey := metrics.ConfigKey("")
+	if local {
+		configKey = body.ConfigKey
+	}
+	inflight := metrics.SQLInflight.WithLabelValues(plugin, configKey)
+	inflight.Inc()
+	// SQL 语句可能包含业务数据，只记录操作类型
+	operation := sqlOperation(body.SQL)
+	ctx, span := tracing.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(
+		attribute.String("db.system", dbSystem),
+		attribute.String("db.operation.name", operation),
+		attribute.String("db.namespace", body.Database),
+		tracing.ConfigKey(body.ConfigKey),
+	)
+	record := audit.FromContext(ctx)
+	record.SetSQL(body.ConfigKey, body.SQL)
+	startTime := time.Now()
+	return ctx, func(qr *QueryResult) {
+		inflight.Dec()
+		result := "success"
+		var err error
+		if qr == nil || qr.Message != "success" {
+			result = "error"
+			err = errors.New("SQL 执行失败")
+			if qr != nil {
+				err = errors.New(qr.Message)
+			}
+		}
+		metrics.SQLQueryDuration.WithLabelValues(plugin, configKey, result).Observe(time.Since(startTime).Seconds())
+		if err == nil {
+			metrics.SQLRows.WithLabelValues(plugin, configKey).Observe(float64(len(qr.Result)))
+			span.SetAttributes(attribute.Int("db.response.returned_rows", len(qr.Result)))
+			record.SetRows(len(qr.Result))
+		}
+		tracing.End(span, err)
+	}
+}
+
+// sqlOperation 返回 SQL 语句的第一个关键字，例如 SELECT
+func sqlOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// findSQLConfig 返回 key 对应的本地配置，key 为空或没有对应配置时为 nil
+func findSQLConfig(configs []Body, key string) *Body {
+	if key == "" {
+		return nil
+	}
+	for _, c := range configs {
+		if c.ConfigKey == key {
+			return &c
+		}
+	}
+	return nil
+}
+
+// sqlConfigKeys 返回配置列表中的 config_key
+func sqlConfigKeys(configs []Body) []string {
+	keys := make([]string, 0, len(configs))
+	for _, c := range configs {
+		keys = append(keys, c.ConfigKey)
+	}
+	return keys
 }
 
 type FlexInt int
@@ -4,7 +4,12 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
 	plugin "github.com/open-dingtalk/ipaas-agent/pkg/plugins"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 )
 
@@ -177,3 +182,63 @@ func TestMSSQLPlugin_doSQLExecute2(t *testing.T) {
 
 	require.Equal(t, "success", qr.Message)
 }
+
+func TestSQLExecute_Metrics(t *testing.T) {
+	p := plugin.NewMySQLPlugin()
+	remote := func() uint64 {
+		var m dto.Metric
+		require.NoError(t, metrics.SQLQueryDuration.WithLabelValues(p.Name, "remote", "error").(prometheus.Metric).Write(&m))
+		return m.GetHistogram().GetSampleCount()
+	}
+
+	// 未在本地配置的 config_key 统一记为 remote，避免标签值无限增长
+	before := remote()
+	qr := p.DoSQLExecute(&plugin.Body{Address: "127.0.0.1:1", ConfigKey: "unreachable", SQL: "SELECT 1"})
+	require.NotEqual(t, "success", qr.Message)
+	require.Equal(t, before+1, remote())
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.SQLInflight.WithLabelValues(p.Name, "remote")))
+	require.Zero(t, countSeries(t, "ipaas_agent_sql_query_duration_seconds", "unreachable"))
+}
+
+// countSeries 返回指标中 config_key 为 configKey 的序列数
+func countSeries(t *testing.T, name, configKey string) int {
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	n := 0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "config_key" && label.GetValue() == configKey {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+func TestSQLExecute_Pool(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("plugins.mysql", []map[string]interface{}{
+		{"config_key": "orders", "address": "127.0.0.1:1", "user": "u", "database": "orders"},
+	})
+	p := plugin.NewMySQLPlugin()
+	require.NoError(t, p.Init())
+
+	body := &plugin.Body{Address: "127.0.0.1:1", User: "u", Database: "orders", ConfigKey: "orders", SQL: "SELECT 1"}
+	for i := 0; i < 2; i++ {
+		require.NotEqual(t, "success", p.DoSQLExecute(body).Message)
+	}
+	var m dto.Metric
+	require.NoError(t, metrics.SQLQueryDuration.WithLabelValues(p.Name, "orders", "error").(prometheus.Metric).Write(&m))
+	require.EqualValues(t, 2, m.GetHistogram().GetSampleCount())
+
+	// 本地配置的连接池状态按 config_key 导出，关闭插件后随之消失
+	require.Equal(t, 1, countSeries(t, "ipaas_agent_sql_pool_open_connections", "orders"))
+	require.Equal(t, 1, countSeries(t, "ipaas_agent_sql_pool_idle_connections", "orders"))
+	require.NoError(t, p.Close())
+	require.Zero(t, countSeries(t, "ipaas_agent_sql_pool_open_connections", "orders"))
+}
@@ -0,0 +1,166 @@
+package plugins
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+)
+
+// sqlConnMaxIdleTime 连接池中的空闲连接保留时间，避免长期占用数据库的连接数
+const sqlConnMaxIdleTime = 5 * time.Minute
+
+// sqlPoolKey 连接池按插件和 config_key 区分
+type sqlPoolKey struct {
+	plugin    string
+	configKey string
+}
+
+// sqlPool 连接池及创建时使用的连接参数；被替换或关闭后标记为 retired，
+// 等正在使用的查询全部结束后关闭
+type sqlPool struct {
+	conf Body
+	// 创建连接池时插件配置的版本
+	gen     uint64
+	db      *sql.DB
+	refs    int
+	retired bool
+}
+
+var (
+	sqlPoolsMu sync.Mutex
+	// 本地配置的连接池，远程配置每次查询单独建立连接
+	sqlPools = make(map[sqlPoolKey]*sqlPool)
+	// 插件配置的版本，每次加载配置后加一
+	sqlPoolGens = make(map[string]uint64)
+)
+
+func init() {
+	metrics.SetSQLPoolSource(sqlPoolStats)
+}
+
+// sqlPoolGen 返回插件当前配置的版本，需要与读取插件配置在同一次加锁中调用
+func sqlPoolGen(plugin string) uint64 {
+	sqlPoolsMu.Lock()
+	defer sqlPoolsMu.Unlock()
+	return sqlPoolGens[plugin]
+}
+
+// getSQLConnection 返回查询使用的连接，查询结束后调用 release；
+// local 为 config_key 对应的本地配置，gen 为读取 local 时插件配置的版本。
+// 本地配置复用 config_key 对应的连接池，远程配置的连接在 release 时关闭；
+// 按热加载之前的旧配置执行的查询不会替换新的连接池，而是单独建立连接
+func getSQLConnection(executor SQLExecutor, plugin string, gen uint64, local *Body, body *Body) (*sql.DB, func(), error) {
+	if local == nil {
+		return openSQLConnection(executor, body)
+	}
+
+	key := sqlPoolKey{plugin: plugin, configKey: local.ConfigKey}
+	conf := *local
+	conf.SQL = ""
+	sqlPoolsMu.Lock()
+	old := sqlPools[key]
+	if old != nil && old.conf == conf {
+		old.refs++
+		sqlPoolsMu.Unlock()
+		return old.db, old.release, nil
+	}
+	if (old != nil && old.gen >= gen) || gen < sqlPoolGens[plugin] {
+		sqlPoolsMu.Unlock()
+		return openSQLConnection(executor, &conf)
+	}
+	// sql.Open 不会建立连接，持有锁的时间很短
+	db, err := executor.GetConnection(&conf)
+	if err != nil {
+		sqlPoolsMu.Unlock()
+		return nil, nil, err
+	}
+	db.SetConnMaxIdleTime(sqlConnMaxIdleTime)
+	pool := &sqlPool{conf: conf, gen: gen, db: db, refs: 1}
+	sqlPools[key] = pool
+	idle := old != nil && old.retire()
+	sqlPoolsMu.Unlock()
+	if idle {
+		old.db.Close()
+	}
+	return db, pool.release, nil
+}
+
+// openSQLConnection 建立单次查询使用的连接，release 时关闭
+func openSQLConnection(executor SQLExecutor, body *Body) (*sql.DB, func(), error) {
+	db, err := executor.GetConnection(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, func() { db.Close() }, nil
+}
+
+// release 查询结束，连接池已被替换且没有其他查询在使用时关闭
+func (pool *sqlPool) release() {
+	sqlPoolsMu.Lock()
+	pool.refs--
+	idle := pool.retired && pool.refs == 0
+	sqlPoolsMu.Unlock()
+	if idle {
+		pool.db.Close()
+	}
+}
+
+// retire 标记连接池不再使用，返回是否可以立即关闭 (没有查询在使用)，需持有 sqlPoolsMu
+func (pool *sqlPool) retire() bool {
+	pool.retired = true
+	return pool.refs == 0
+}
+
+// closeSQLPools 插件关闭时关闭插件的全部连接池，正在执行的查询结束后才关闭
+func closeSQLPools(plugin string) {
+	reloadSQLPools(plugin, nil)
+}
+
+// reloadSQLPools 插件加载配置后关闭被修改或删除的 config_key 对应的连接池，
+// diff 为 nil 或 auth 部分变化时关闭全部连接池；需与替换插件配置在同一次加锁中调用。
+// 正在执行的查询结束后才关闭
+func reloadSQLPools(plugin string, diff *configv1.PluginDiff) {
+	sqlPoolsMu.Lock()
+	sqlPoolGens[plugin]++
+	gen := sqlPoolGens[plugin]
+	var idle []*sql.DB
+	for key, pool := range sqlPools {
+		if key.plugin != plugin {
+			continue
+		}
+		if diff != nil && !diff.AuthChanged && !diff.Affects(key.configKey) {
+			// 未变化的连接池视为按新配置创建
+			pool.gen = gen
+			continue
+		}
+		delete(sqlPools, key)
+		if pool.retire() {
+			idle = append(idle, pool.db)
+		}
+	}
+	sqlPoolsMu.Unlock()
+	for _, db := range idle {
+		db.Close()
+	}
+}
+
+// sqlPoolStats 返回所有连接池的状态，按插件和 config_key 排序
+func sqlPoolStats() []metrics.SQLPoolStats {
+	sqlPoolsMu.Lock()
+	stats := make([]metrics.SQLPoolStats, 0, len(sqlPools))
+	for key, pool := range sqlPools {
+		stats = append(stats, metrics.SQLPoolStats{Plugin: key.plugin, ConfigKey: key.configKey, Stats: pool.db.Stats()})
+	}
+	sqlPoolsMu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Plugin != stats[j].Plugin {
+			return stats[i].Plugin < stats[j].Plugin
+		}
+		return stats[i].ConfigKey < stats[j].ConfigKey
+	})
+	return stats
+}
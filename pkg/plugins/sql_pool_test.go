@@ -0,0 +1,104 @@
+package plugins
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	configv1 "github.com/open-dingtalk/ipaas-agent/pkg/config/v1"
+)
+
+// pingClosed 判断连接池是否已关闭，未关闭时连接不存在的地址返回其他错误
+func pingClosed(t *testing.T, db *sql.DB) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := db.PingContext(ctx)
+	require.Error(t, err)
+	return err.Error() == "sql: database is closed"
+}
+
+func TestSQLPool_Reload(t *testing.T) {
+	const plugin = "sql_pool_test"
+	executor := &MySQLPlugin{}
+	defer closeSQLPools(plugin)
+
+	local := &Body{ConfigKey: "orders", Address: "127.0.0.1:1", User: "u", Database: "orders"}
+	gen := sqlPoolGen(plugin)
+	db, release, err := getSQLConnection(executor, plugin, gen, local, local)
+	require.NoError(t, err)
+	same, releaseSame, err := getSQLConnection(executor, plugin, gen, local, local)
+	require.NoError(t, err)
+	require.Same(t, db, same)
+	releaseSame()
+
+	// 重新加载配置后，正在使用的连接池在查询结束前不会关闭
+	closeSQLPools(plugin)
+	require.False(t, pingClosed(t, db))
+
+	changed := *local
+	changed.Database = "orders_v2"
+	newDB, releaseNew, err := getSQLConnection(executor, plugin, sqlPoolGen(plugin), &changed, &changed)
+	require.NoError(t, err)
+	require.NotSame(t, db, newDB)
+
+	// 按旧配置执行的查询单独建立连接，不替换新的连接池
+	staleDB, releaseStale, err := getSQLConnection(executor, plugin, gen, local, local)
+	require.NoError(t, err)
+	require.NotSame(t, newDB, staleDB)
+	releaseStale()
+	require.True(t, pingClosed(t, staleDB))
+	require.Equal(t, newDB, sqlPools[sqlPoolKey{plugin: plugin, configKey: "orders"}].db)
+
+	release()
+	require.True(t, pingClosed(t, db))
+	releaseNew()
+	require.False(t, pingClosed(t, newDB))
+}
+
+func TestSQLPool_PluginReload(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("plugins.mysql", []map[string]interface{}{
+		{"config_key": "orders", "address": "127.0.0.1:1", "user": "u", "database": "orders"},
+		{"config_key": "users", "address": "127.0.0.1:1", "user": "u", "database": "users"},
+	})
+	p := &MySQLPlugin{Name: "sql_pool_reload_test"}
+	require.NoError(t, p.Init())
+	defer p.Close()
+
+	pools := func() map[string]*sql.DB {
+		dbs := make(map[string]*sql.DB)
+		for _, key := range []string{"orders", "users"} {
+			p.mu.RLock()
+			local := findSQLConfig(p.Configs, key)
+			gen := sqlPoolGen(p.Name)
+			p.mu.RUnlock()
+			db, release, err := getSQLConnection(p, p.Name, gen, local, local)
+			require.NoError(t, err)
+			release()
+			dbs[key] = db
+		}
+		return dbs
+	}
+	before := pools()
+
+	// 只关闭被修改的 config_key 对应的连接池
+	viper.Set("plugins.mysql", []map[string]interface{}{
+		{"config_key": "orders", "address": "127.0.0.1:1", "user": "u", "database": "orders_v2"},
+		{"config_key": "users", "address": "127.0.0.1:1", "user": "u", "database": "users"},
+	})
+	require.NoError(t, p.Reload(&configv1.PluginDiff{Changed: []string{"orders"}}))
+	require.True(t, pingClosed(t, before["orders"]))
+	require.False(t, pingClosed(t, before["users"]))
+	after := pools()
+	require.NotSame(t, before["orders"], after["orders"])
+	require.Same(t, before["users"], after["users"])
+
+	// auth 部分变化时关闭全部连接池
+	require.NoError(t, p.Reload(&configv1.PluginDiff{AuthChanged: true}))
+	require.True(t, pingClosed(t, after["orders"]))
+	require.True(t, pingClosed(t, after["users"]))
+}
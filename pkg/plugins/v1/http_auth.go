@@ -0,0 +1,294 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HTTPAuthBasic  = "basic"
+	HTTPAuthBearer = "bearer"
+	HTTPAuthAPIKey = "api_key"
+	HTTPAuthOAuth2 = "oauth2"
+	HTTPAuthHMAC   = "hmac"
+)
+
+// HTTPAuthConfig 上游鉴权配置，凭证只保存在本地
+type HTTPAuthConfig struct {
+	// basic | bearer | api_key | oauth2 | hmac
+	Type string `json:"type,omitempty" mapstructure:"type,omitempty"`
+	// basic
+	Username string `json:"username,omitempty" mapstructure:"username,omitempty"`
+	Password string `json:"password,omitempty" mapstructure:"password,omitempty"`
+	// bearer
+	Token string `json:"token,omitempty" mapstructure:"token,omitempty"`
+	// api_key: 请求头名称 (默认 X-API-Key) 和值
+	Header string `json:"header,omitempty" mapstructure:"header,omitempty"`
+	Key    string `json:"key,omitempty" mapstructure:"key,omitempty"`
+	// oauth2 client credentials
+	TokenURL       string            `json:"token_url,omitempty" mapstructure:"token_url,omitempty"`
+	ClientID       string            `json:"client_id,omitempty" mapstructure:"client_id,omitempty"`
+	ClientSecret   string            `json:"client_secret,omitempty" mapstructure:"client_secret,omitempty"`
+	Scopes         []string          `json:"scopes,omitempty" mapstructure:"scopes,omitempty"`
+	EndpointParams map[string]string `json:"endpoint_params,omitempty" mapstructure:"endpoint_params,omitempty"`
+	// hmac 签名: 签名串为 METHOD\nPATH?QUERY\nTIMESTAMP\nHEX(SHA256(BODY))
+	Secret          string `json:"secret,omitempty" mapstructure:"secret,omitempty"`
+	Algorithm       string `json:"algorithm,omitempty" mapstructure:"algorithm,omitempty"`               // sha256 (默认) | sha1 | sha512
+	Encoding        string `json:"encoding,omitempty" mapstructure:"encoding,omitempty"`                 // hex (默认) | base64
+	SignatureHeader string `json:"signature_header,omitempty" mapstructure:"signature_header,omitempty"` // 默认 X-Signature
+	TimestampHeader string `json:"timestamp_header,omitempty" mapstructure:"timestamp_header,omitempty"` // 默认 X-Timestamp
+}
+
+type httpAuthenticator interface {
+	Apply(req *http.Request) error
+}
+
+// tokenInvalidator 令牌可以被刷新的鉴权方式
+type tokenInvalidator interface {
+	Invalidate()
+}
+
+func newHTTPAuthenticator(conf *HTTPAuthConfig, client *http.Client) (httpAuthenticator, error) {
+	switch strings.ToLower(conf.Type) {
+	case "":
+		return nil, nil
+	case HTTPAuthBasic:
+		if conf.Username == "" {
+			return nil, fmt.Errorf("basic 鉴权缺少 username")
+		}
+		return &basicAuth{username: conf.Username, password: conf.Password}, nil
+	case HTTPAuthBearer:
+		if conf.Token == "" {
+			return nil, fmt.Errorf("bearer 鉴权缺少 token")
+		}
+		return &headerAuth{header: "Authorization", value: "Bearer " + conf.Token}, nil
+	case HTTPAuthAPIKey:
+		if conf.Key == "" {
+			return nil, fmt.Errorf("api_key 鉴权缺少 key")
+		}
+		header := conf.Header
+		if header == "" {
+			header = "X-API-Key"
+		}
+		return &headerAuth{header: header, value: conf.Key}, nil
+	case HTTPAuthOAuth2:
+		if conf.TokenURL == "" || conf.ClientID == "" {
+			return nil, fmt.Errorf("oauth2 鉴权缺少 token_url 或 client_id")
+		}
+		return &oauth2Auth{conf: conf, client: client}, nil
+	case HTTPAuthHMAC:
+		if conf.Secret == "" {
+			return nil, fmt.Errorf("hmac 鉴权缺少 secret")
+		}
+		return newHMACAuth(conf)
+	default:
+		return nil, fmt.Errorf("不支持的鉴权方式: %s", conf.Type)
+	}
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func (a *basicAuth) Apply(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+type headerAuth struct {
+	header string
+	value  string
+}
+
+func (a *headerAuth) Apply(req *http.Request) error {
+	req.Header.Set(a.header, a.value)
+	return nil
+}
+
+// oauth2Auth client credentials 模式，令牌在过期前缓存复用
+type oauth2Auth struct {
+	conf   *HTTPAuthConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	token     string
+	tokenType string
+	expiresAt time.Time
+}
+
+// 提前刷新令牌的时间
+const oauth2ExpiryDelta = time.Minute
+
+type oauth2TokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	TokenType   string      `json:"token_type"`
+	ExpiresIn   json.Number `json:"expires_in"`
+}
+
+func (a *oauth2Auth) Apply(req *http.Request) error {
+	token, tokenType, err := a.getToken(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", tokenType+" "+token)
+	return nil
+}
+
+func (a *oauth2Auth) Invalidate() {
+	a.mu.Lock()
+	a.token = ""
+	a.mu.Unlock()
+}
+
+func (a *oauth2Auth) getToken(ctx context.Context) (string, string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && (a.expiresAt.IsZero() || time.Now().Before(a.expiresAt)) {
+		return a.token, a.tokenType, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", a.conf.ClientID)
+	form.Set("client_secret", a.conf.ClientSecret)
+	if len(a.conf.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.conf.Scopes, " "))
+	}
+	for k, v := range a.conf.EndpointParams {
+		form.Set(k, v)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.conf.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("获取 OAuth2 令牌失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", "", fmt.Errorf("读取 OAuth2 令牌失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("获取 OAuth2 令牌失败: %s", resp.Status)
+	}
+	var tr oauth2TokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", "", fmt.Errorf("解析 OAuth2 令牌失败: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return "", "", fmt.Errorf("OAuth2 响应中没有 access_token")
+	}
+
+	a.token = tr.AccessToken
+	a.tokenType = "Bearer"
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		a.tokenType = tr.TokenType
+	}
+	a.expiresAt = time.Time{}
+	if seconds, err := tr.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		a.expiresAt = time.Now().Add(time.Duration(seconds)*time.Second - oauth2ExpiryDelta)
+	}
+	return a.token, a.tokenType, nil
+}
+
+// hmacAuth 对请求进行 HMAC 签名
+type hmacAuth struct {
+	secret          []byte
+	newHash         func() hash.Hash
+	base64          bool
+	signatureHeader string
+	timestampHeader string
+}
+
+func newHMACAuth(conf *HTTPAuthConfig) (*hmacAuth, error) {
+	a := &hmacAuth{
+		secret:          []byte(conf.Secret),
+		signatureHeader: conf.SignatureHeader,
+		timestampHeader: conf.TimestampHeader,
+	}
+	switch strings.ToLower(conf.Algorithm) {
+	case "", "sha256":
+		a.newHash = sha256.New
+	case "sha1":
+		a.newHash = sha1.New
+	case "sha512":
+		a.newHash = sha512.New
+	default:
+		return nil, fmt.Errorf("不支持的 hmac 算法: %s", conf.Algorithm)
+	}
+	switch strings.ToLower(conf.Encoding) {
+	case "", "hex":
+	case "base64":
+		a.base64 = true
+	default:
+		return nil, fmt.Errorf("不支持的签名编码: %s", conf.Encoding)
+	}
+	if a.signatureHeader == "" {
+		a.signatureHeader = "X-Signature"
+	}
+	if a.timestampHeader == "" {
+		a.timestampHeader = "X-Timestamp"
+	}
+	return a, nil
+}
+
+// hmacStringToSign 返回签名原文
+func hmacStringToSign(req *http.Request, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		req.Method,
+		req.URL.RequestURI(),
+		timestamp,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+func (a *hmacAuth) Apply(req *http.Request) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	mac := hmac.New(a.newHash, a.secret)
+	mac.Write([]byte(hmacStringToSign(req, timestamp, body)))
+	sum := mac.Sum(nil)
+
+	signature := hex.EncodeToString(sum)
+	if a.base64 {
+		signature = base64.StdEncoding.EncodeToString(sum)
+	}
+	req.Header.Set(a.timestampHeader, timestamp)
+	req.Header.Set(a.signatureHeader, signature)
+	return nil
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// BodyEncodingBase64 请求或响应体使用 base64 编码
+	BodyEncodingBase64 = "base64"
+	// BodyEncodingText 响应体按文本返回
+	BodyEncodingText = "text"
+)
+
+// MultipartField multipart/form-data 中的一个字段或文件
+type MultipartField struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+	// 以下字段用于文件，Content 为 base64 编码的文件内容
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Content     string `json:"content,omitempty"`
+}
+
+// BuildBody 构造请求体，GraphQL 和 multipart 时同时返回对应的 Content-Type
+func (r *HTTPRequest) BuildBody() ([]byte, string, error) {
+	if r.GraphQL != nil {
+		return buildGraphQLBody(r.GraphQL)
+	}
+	if len(r.Multipart) > 0 {
+		return buildMultipartBody(r.Multipart)
+	}
+	if strings.EqualFold(r.BodyEncoding, BodyEncodingBase64) {
+		body, err := base64.StdEncoding.DecodeString(r.Body)
+		if err != nil {
+			return nil, "", fmt.Errorf("请求体不是有效的 base64: %w", err)
+		}
+		return body, "", nil
+	}
+	return []byte(r.Body), "", nil
+}
+
+func buildMultipartBody(fields []MultipartField) ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, field := range fields {
+		if field.FileName == "" {
+			if err := writer.WriteField(field.Name, field.Value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		content, err := base64.StdEncoding.DecodeString(field.Content)
+		if err != nil {
+			return nil, "", fmt.Errorf("文件 %s 的内容不是有效的 base64: %w", field.FileName, err)
+		}
+		contentType := field.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+			"name":     field.Name,
+			"filename": field.FileName,
+		}))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// IsTextContentType 判断响应是否为文本，未声明类型时根据内容是否为合法 UTF-8 判断
+func IsTextContentType(contentType string, body []byte) bool {
+	if contentType == "" {
+		return utf8.Valid(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return utf8.Valid(body)
+	}
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-www-form-urlencoded", "application/yaml", "application/x-yaml",
+		"application/graphql", "application/x-ndjson":
+		return true
+	}
+	return false
+}
+
+// EncodeResponseBody 按请求指定的编码或响应类型返回响应体，二进制内容使用 base64
+func EncodeResponseBody(body []byte, contentType, encoding string) (string, string) {
+	switch strings.ToLower(encoding) {
+	case BodyEncodingBase64:
+		return base64.StdEncoding.EncodeToString(body), BodyEncodingBase64
+	case BodyEncodingText:
+		return string(body), ""
+	}
+	if IsTextContentType(contentType, body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), BodyEncodingBase64
+}
@@ -0,0 +1,83 @@
+package v1_test
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHTTPRequest_Multipart(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0xff}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		require.Equal(t, "report", r.FormValue("title"))
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer file.Close()
+		require.Equal(t, "logo.png", header.Filename)
+		require.Equal(t, "image/png", header.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "image/png")
+		io.Copy(w, file)
+	}))
+	defer server.Close()
+
+	resp, err := v1.HandleHTTPRequest(v1.HTTPRequest{
+		Method: "POST",
+		URL:    server.URL,
+		Multipart: []v1.MultipartField{
+			{Name: "title", Value: "report"},
+			{Name: "file", FileName: "logo.png", ContentType: "image/png", Content: base64.StdEncoding.EncodeToString(png)},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "image/png", resp.ContentType)
+	require.Equal(t, v1.BodyEncodingBase64, resp.BodyEncoding)
+	require.Equal(t, base64.StdEncoding.EncodeToString(png), resp.Body)
+}
+
+func TestHandleHTTPRequest_Base64Body(t *testing.T) {
+	pdf := []byte("%PDF-1.7\x00\xe2\xe3")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.Equal(t, pdf, body)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("received"))
+	}))
+	defer server.Close()
+
+	resp, err := v1.HandleHTTPRequest(v1.HTTPRequest{
+		Method:       "PUT",
+		URL:          server.URL,
+		ContentType:  "application/pdf",
+		Body:         base64.StdEncoding.EncodeToString(pdf),
+		BodyEncoding: v1.BodyEncodingBase64,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "received", resp.Body)
+	require.Empty(t, resp.BodyEncoding)
+
+	// 强制以 base64 返回文本响应
+	resp, err = v1.HandleHTTPRequest(v1.HTTPRequest{
+		Method:           "PUT",
+		URL:              server.URL,
+		Body:             base64.StdEncoding.EncodeToString(pdf),
+		BodyEncoding:     v1.BodyEncodingBase64,
+		ResponseEncoding: v1.BodyEncodingBase64,
+	})
+	require.NoError(t, err)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte("received")), resp.Body)
+}
+
+func TestIsTextContentType(t *testing.T) {
+	require.True(t, v1.IsTextContentType("application/json; charset=utf-8", nil))
+	require.True(t, v1.IsTextContentType("application/vnd.api+json", nil))
+	require.True(t, v1.IsTextContentType("", []byte("plain")))
+	require.False(t, v1.IsTextContentType("", []byte{0xff, 0xfe}))
+	require.False(t, v1.IsTextContentType("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", []byte("PK")))
+}
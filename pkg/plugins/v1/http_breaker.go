@@ -0,0 +1,215 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+)
+
+// HTTPCircuitBreakerConfig 上游熔断配置
+type HTTPCircuitBreakerConfig struct {
+	// 连续失败多少次后熔断，0 表示不启用
+	FailureThreshold int `json:"failure_threshold,omitempty" mapstructure:"failure_threshold,omitempty"`
+	// 熔断持续时间，单位毫秒，默认 30000，之后进入半开状态
+	OpenTimeout int `json:"open_timeout,omitempty" mapstructure:"open_timeout,omitempty"`
+	// 半开状态下允许通过的探测请求数，默认 1
+	HalfOpenRequests int `json:"half_open_requests,omitempty" mapstructure:"half_open_requests,omitempty"`
+}
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// circuitBreaker 连续失败达到阈值后快速失败，超时后放行少量请求探测上游是否恢复
+type circuitBreaker struct {
+	configKey        string
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenRequests int
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	inFlight int
+}
+
+func newCircuitBreaker(configKey string, conf *HTTPCircuitBreakerConfig) *circuitBreaker {
+	if conf.FailureThreshold <= 0 {
+		return nil
+	}
+	b := &circuitBreaker{
+		configKey:        configKey,
+		failureThreshold: conf.FailureThreshold,
+		openTimeout:      time.Duration(conf.OpenTimeout) * time.Millisecond,
+		halfOpenRequests: conf.HalfOpenRequests,
+		state:            BreakerClosed,
+	}
+	if b.openTimeout <= 0 {
+		b.openTimeout = 30 * time.Second
+	}
+	if b.halfOpenRequests <= 0 {
+		b.halfOpenRequests = 1
+	}
+	return b
+}
+
+// setState 切换状态并记录日志，调用方需持有 b.mu
+func (b *circuitBreaker) setState(state string) {
+	if b.state == state {
+		return
+	}
+	entry := logger.Log1.
+		WithField("configKey", b.configKey).
+		WithField("from", b.state).
+		WithField("to", state).
+		WithField("failures", b.failures)
+	b.state = state
+	switch state {
+	case BreakerOpen:
+		b.openedAt = time.Now()
+		entry.Warn("HTTP 上游熔断")
+	case BreakerHalfOpen:
+		b.inFlight = 0
+		entry.Info("HTTP 上游熔断半开，开始探测")
+	case BreakerClosed:
+		b.failures = 0
+		entry.Info("HTTP 上游已恢复")
+	}
+}
+
+// allow 判断请求是否可以通过
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if time.Since(b.openedAt) < b.openTimeout {
+			return &HTTPCircuitOpenError{ConfigKey: b.configKey, RetryAfter: b.openTimeout - time.Since(b.openedAt)}
+		}
+		b.setState(BreakerHalfOpen)
+	}
+	if b.state == BreakerHalfOpen {
+		if b.inFlight >= b.halfOpenRequests {
+			return &HTTPCircuitOpenError{ConfigKey: b.configKey}
+		}
+		b.inFlight++
+	}
+	return nil
+}
+
+// record 记录一次请求结果，网络错误和 5xx 视为失败
+func (b *circuitBreaker) record(resp *http.Response, err error) {
+	if b == nil {
+		return
+	}
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	// 本地拒绝的请求没有到达上游
+	_, local := AsHTTPErrorResponse(err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		if b.inFlight > 0 {
+			b.inFlight--
+		}
+		if local {
+			return
+		}
+		if failed {
+			b.setState(BreakerOpen)
+		} else {
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+	if local {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.failureThreshold {
+		b.setState(BreakerOpen)
+	}
+}
+
+// BreakerStatus 熔断器状态，用于日志和状态输出
+type BreakerStatus struct {
+	ConfigKey string     `json:"configKey"`
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenedAt  *time.Time `json:"openedAt,omitempty"`
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BreakerStatus{ConfigKey: b.configKey, State: b.state, Failures: b.failures}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		s.OpenedAt = &openedAt
+	}
+	return s
+}
+
+// HTTPCircuitOpenError 上游处于熔断状态，请求被快速失败
+type HTTPCircuitOpenError struct {
+	ConfigKey  string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPCircuitOpenError) Error() string {
+	return fmt.Sprintf("HTTP 上游 %s 已熔断", e.ConfigKey)
+}
+
+// Response 转换为结构化的 503 响应
+func (e *HTTPCircuitOpenError) Response() *HTTPResponse {
+	content := map[string]interface{}{
+		"error":    "circuit_open",
+		"upstream": e.ConfigKey,
+	}
+	headers := map[string]string{}
+	if e.RetryAfter > 0 {
+		retryAfter := fmt.Sprintf("%d", int(e.RetryAfter.Seconds())+1)
+		content["retryAfter"] = retryAfter
+		headers["Retry-After"] = retryAfter
+	}
+	body, _ := json.Marshal(content)
+	return &HTTPResponse{
+		Status:     "503 Service Unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+		Proto:      "HTTP/1.1",
+		Header:     headers,
+		Body:       string(body),
+		Content:    content,
+	}
+}
+
+// HTTPBreakerStatus 返回所有启用熔断的上游状态
+func HTTPBreakerStatus() []BreakerStatus {
+	var statuses []BreakerStatus
+	if upstreams := httpUpstreams.Load(); upstreams != nil {
+		for _, u := range *upstreams {
+			if u.breaker != nil {
+				statuses = append(statuses, u.breaker.status())
+			}
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ConfigKey < statuses[j].ConfigKey
+	})
+	return statuses
+}
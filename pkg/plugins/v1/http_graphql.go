@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// GraphQLRequest GraphQL 查询，设置后请求体由插件构造并以 POST 发送
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// GraphQLLocation 错误在查询语句中的位置
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError GraphQL 响应中的错误，可能与 HTTP 200 一起返回
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// RequestMethod 返回实际使用的请求方法，GraphQL 请求总是使用 POST
+func (r *HTTPRequest) RequestMethod() string {
+	if r.GraphQL != nil {
+		return http.MethodPost
+	}
+	return r.Method
+}
+
+func buildGraphQLBody(q *GraphQLRequest) ([]byte, string, error) {
+	if q.Query == "" {
+		return nil, "", errors.New("GraphQL 请求缺少 query")
+	}
+	body, err := json.Marshal(q)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, "application/json", nil
+}
+
+// ApplyGraphQLResponse 将 GraphQL 响应体拆分为 data 和 errors；
+// 响应体不是 GraphQL 格式时返回 false，由调用方按普通 HTTP 响应处理
+func ApplyGraphQLResponse(resp *HTTPResponse, body []byte) bool {
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []GraphQLError  `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false
+	}
+	if result.Data == nil && result.Errors == nil {
+		return false
+	}
+	var data interface{}
+	if len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, &data); err != nil {
+			return false
+		}
+	}
+	resp.Data = data
+	resp.Errors = result.Errors
+	return true
+}
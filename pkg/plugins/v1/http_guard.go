@@ -0,0 +1,386 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+)
+
+// 默认禁止访问的网段: 链路本地地址以及常见云厂商的元数据服务
+var defaultDenyCIDRs = []string{
+	"169.254.0.0/16",
+	"fe80::/10",
+	"100.100.100.200/32",
+	"fd00:ec2::254/128",
+}
+
+// HTTPGuardConfig HTTP 出站访问控制 auth.http
+//
+// 同一类规则中 deny 优先；allow 列表为空表示不限制，不为空时必须命中。
+// 不同类别的规则需要同时满足。
+type HTTPGuardConfig struct {
+	AllowHosts        []string `mapstructure:"allow_hosts"`
+	DenyHosts         []string `mapstructure:"deny_hosts"`
+	AllowCIDRs        []string `mapstructure:"allow_cidrs"`
+	DenyCIDRs         []string `mapstructure:"deny_cidrs"`
+	AllowPorts        []int    `mapstructure:"allow_ports"`
+	DenyPorts         []int    `mapstructure:"deny_ports"`
+	AllowPathPrefixes []string `mapstructure:"allow_path_prefixes"`
+	DenyPathPrefixes  []string `mapstructure:"deny_path_prefixes"`
+	// 默认禁止的链路本地和元数据服务网段总是追加到 deny_cidrs 中，设为 true 时不追加
+	DisableDefaultDeny bool `mapstructure:"disable_default_deny"`
+}
+
+// HTTPGuard 校验出站 HTTP 请求的目标，防止 SSRF
+type HTTPGuard struct {
+	conf       HTTPGuardConfig
+	allowCIDRs []*net.IPNet
+	denyCIDRs  []*net.IPNet
+}
+
+// HTTPGuardError 被访问控制拒绝的请求
+type HTTPGuardError struct {
+	Reason string `json:"reason"`
+	Target string `json:"target"`
+}
+
+func (e *HTTPGuardError) Error() string {
+	return fmt.Sprintf("请求被访问控制拒绝: %s (%s)", e.Reason, e.Target)
+}
+
+var httpGuard atomic.Pointer[HTTPGuard]
+
+func init() {
+	guard, _ := NewHTTPGuard(HTTPGuardConfig{DenyCIDRs: defaultDenyCIDRs})
+	httpGuard.Store(guard)
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if !strings.Contains(c, "/") {
+			// 单个 IP
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR %q: %w", c, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func NewHTTPGuard(conf HTTPGuardConfig) (*HTTPGuard, error) {
+	allow, err := parseCIDRs(conf.AllowCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseCIDRs(conf.DenyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPGuard{conf: conf, allowCIDRs: allow, denyCIDRs: deny}, nil
+}
+
+// LoadHTTPGuard 从配置文件 auth.http 加载访问控制规则
+func LoadHTTPGuard() error {
+	var conf HTTPGuardConfig
+	if err := viper.UnmarshalKey("auth.http", &conf); err != nil {
+		return fmt.Errorf("解析 HTTP 访问控制配置出错: %w", err)
+	}
+	if !conf.DisableDefaultDeny {
+		// 配置了其它网段时同样禁止访问元数据服务
+		for _, cidr := range defaultDenyCIDRs {
+			if !slices.Contains(conf.DenyCIDRs, cidr) {
+				conf.DenyCIDRs = append(conf.DenyCIDRs, cidr)
+			}
+		}
+	}
+	guard, err := NewHTTPGuard(conf)
+	if err != nil {
+		return err
+	}
+	httpGuard.Store(guard)
+	logger.Log1.WithField("规则", conf).Info("HTTP 访问控制已加载")
+	return nil
+}
+
+func GetHTTPGuard() *HTTPGuard {
+	return httpGuard.Load()
+}
+
+// matchHost 支持精确匹配和 *.example.com 形式的通配
+func matchHost(host string, patterns []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "*" || p == host {
+			return true
+		}
+		if strings.HasPrefix(p, "*.") && strings.HasSuffix(host, p[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath 按路径段匹配前缀，/api 匹配 /api 和 /api/users，不匹配 /api-internal
+func matchPath(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		if len(p) == len(prefix) || strings.HasSuffix(prefix, "/") || p[len(prefix)] == '/' {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPort(port int, ports []int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func urlPort(u *url.URL) int {
+	if p := u.Port(); p != "" {
+		port, _ := strconv.Atoi(p)
+		return port
+	}
+	switch u.Scheme {
+	case "https":
+		return 443
+	case "socks5", "socks5h":
+		return 1080
+	}
+	return 80
+}
+
+// CheckURL 校验协议、主机名、端口和路径；目标为 IP 字面量时同时校验网段
+func (g *HTTPGuard) CheckURL(u *url.URL) error {
+	target := u.Redacted()
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &HTTPGuardError{Reason: "不支持的协议 " + u.Scheme, Target: target}
+	}
+	host := u.Hostname()
+	if host == "" {
+		return &HTTPGuardError{Reason: "缺少主机名", Target: target}
+	}
+	if matchHost(host, g.conf.DenyHosts) {
+		return &HTTPGuardError{Reason: "主机在禁止列表中", Target: target}
+	}
+	if len(g.conf.AllowHosts) > 0 && !matchHost(host, g.conf.AllowHosts) {
+		return &HTTPGuardError{Reason: "主机不在允许列表中", Target: target}
+	}
+
+	port := urlPort(u)
+	if containsPort(port, g.conf.DenyPorts) {
+		return &HTTPGuardError{Reason: fmt.Sprintf("端口 %d 在禁止列表中", port), Target: target}
+	}
+	if len(g.conf.AllowPorts) > 0 && !containsPort(port, g.conf.AllowPorts) {
+		return &HTTPGuardError{Reason: fmt.Sprintf("端口 %d 不在允许列表中", port), Target: target}
+	}
+
+	// 规范化解码后的路径，避免 /api/../admin 和 /api/%2e%2e/admin 绕过前缀规则
+	p := path.Clean("/" + u.Path)
+	if strings.HasSuffix(u.Path, "/") && p != "/" {
+		p += "/"
+	}
+	if matchPath(p, g.conf.DenyPathPrefixes) {
+		return &HTTPGuardError{Reason: "路径在禁止列表中", Target: target}
+	}
+	if len(g.conf.AllowPathPrefixes) > 0 && !matchPath(p, g.conf.AllowPathPrefixes) {
+		return &HTTPGuardError{Reason: "路径不在允许列表中", Target: target}
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return g.CheckIP(ip, target)
+	}
+	return nil
+}
+
+// CheckIP 校验 DNS 解析后的目标地址
+func (g *HTTPGuard) CheckIP(ip net.IP, target string) error {
+	if containsIP(ip, g.denyCIDRs) {
+		return &HTTPGuardError{Reason: fmt.Sprintf("地址 %s 在禁止网段中", ip), Target: target}
+	}
+	if len(g.allowCIDRs) > 0 && !containsIP(ip, g.allowCIDRs) {
+		return &HTTPGuardError{Reason: fmt.Sprintf("地址 %s 不在允许网段中", ip), Target: target}
+	}
+	return nil
+}
+
+// resolve 解析主机名并校验所有地址，任何一个地址被拒绝则整体拒绝
+func (g *HTTPGuard) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, g.CheckIP(ip, host)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if err := g.CheckIP(addr.IP, host); err != nil {
+			return nil, err
+		}
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// guardDialer 每个 Transport 独立的拨号器，只放行本 Transport 的代理函数返回过的代理地址
+type guardDialer struct {
+	dialer  *net.Dialer
+	proxies sync.Map
+}
+
+// DialContext 在建立 TCP 连接前解析并校验地址，直接连接校验过的 IP 以防止 DNS rebinding
+func (d *guardDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if _, ok := d.proxies.Load(addr); ok {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := GetHTTPGuard().resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// wrapProxy 包装代理选择函数；经代理转发时由本地解析目标地址进行校验，并放行代理地址
+func (d *guardDialer) wrapProxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL == nil {
+			// 不经代理直接访问代理地址时拨号不再校验，这里提前校验
+			if _, ok := d.proxies.Load(hostPort(req.URL)); ok {
+				if _, err := GetHTTPGuard().resolve(req.Context(), req.URL.Hostname()); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		}
+		if _, err := GetHTTPGuard().resolve(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		d.proxies.Store(hostPort(proxyURL), struct{}{})
+		return proxyURL, nil
+	}
+}
+
+func hostPort(u *url.URL) string {
+	return net.JoinHostPort(u.Hostname(), strconv.Itoa(urlPort(u)))
+}
+
+// guardCheckRedirect 对每一次重定向重新校验
+func guardCheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("重定向次数过多")
+	}
+	return GetHTTPGuard().CheckURL(req.URL)
+}
+
+// NewGuardedHTTPClient 返回经过访问控制的 HTTP 客户端
+func NewGuardedHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport:     guardedTransport,
+		CheckRedirect: guardCheckRedirect,
+		Timeout:       timeout,
+	}
+}
+
+// AsHTTPGuardError 判断错误是否由访问控制引起
+func AsHTTPGuardError(err error) (*HTTPGuardError, bool) {
+	var guardErr *HTTPGuardError
+	if errors.As(err, &guardErr) {
+		return guardErr, true
+	}
+	return nil, false
+}
+
+// NewHTTPGuardResponse 将访问控制错误转换为结构化的 403 响应
+func NewHTTPGuardResponse(err *HTTPGuardError) *HTTPResponse {
+	return err.Response()
+}
+
+// Response 转换为结构化的 403 响应
+func (e *HTTPGuardError) Response() *HTTPResponse {
+	content := map[string]interface{}{
+		"error":  "request_denied",
+		"reason": e.Reason,
+		"target": e.Target,
+	}
+	body, _ := json.Marshal(content)
+	logger.Log1.WithField("target", e.Target).WithField("reason", e.Reason).Warn("HTTP 请求被访问控制拒绝")
+	return &HTTPResponse{
+		Status:     "403 Forbidden",
+		StatusCode: http.StatusForbidden,
+		Proto:      "HTTP/1.1",
+		Body:       string(body),
+		Content:    content,
+	}
+}
+
+// httpErrorResponder 可以直接转换为结构化响应返回给平台的错误
+type httpErrorResponder interface {
+	error
+	Response() *HTTPResponse
+}
+
+// AsHTTPErrorResponse 将访问控制、熔断等本地拒绝的错误转换为结构化响应
+func AsHTTPErrorResponse(err error) (*HTTPResponse, bool) {
+	var responder httpErrorResponder
+	if errors.As(err, &responder) {
+		return responder.Response(), true
+	}
+	return nil, false
+}
@@ -0,0 +1,139 @@
+package v1_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPGuard_CheckURL(t *testing.T) {
+	guard, err := v1.NewHTTPGuard(v1.HTTPGuardConfig{
+		AllowHosts:        []string{"*.corp.example.com", "10.1.2.3"},
+		DenyHosts:         []string{"admin.corp.example.com"},
+		DenyCIDRs:         []string{"169.254.0.0/16"},
+		AllowPorts:        []int{80, 443},
+		AllowPathPrefixes: []string{"/api/"},
+	})
+	require.NoError(t, err)
+
+	cases := map[string]bool{
+		"https://svc.corp.example.com/api/users":    true,
+		"http://10.1.2.3/api/":                      true,
+		"https://admin.corp.example.com/api/users":  false,
+		"https://evil.example.com/api/users":        false,
+		"https://svc.corp.example.com:8443/api/":    false,
+		"https://svc.corp.example.com/internal":     false,
+		"https://svc.corp.example.com/api/../admin": false,
+		"https://svc.corp.example.com/api/%2e%2e/x": false,
+		"https://svc.corp.example.com/api%2f..%2fx": false,
+		"https://svc.corp.example.com/api-x/users":  false,
+		"ftp://svc.corp.example.com/api/":           false,
+	}
+	for raw, allowed := range cases {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		err = guard.CheckURL(u)
+		if allowed {
+			require.NoError(t, err, raw)
+		} else {
+			_, ok := v1.AsHTTPGuardError(err)
+			require.True(t, ok, raw)
+		}
+	}
+}
+
+func TestHTTPGuard_PathPrefixes(t *testing.T) {
+	guard, err := v1.NewHTTPGuard(v1.HTTPGuardConfig{
+		AllowPathPrefixes: []string{"/api", "/public/"},
+		DenyPathPrefixes:  []string{"/api/admin"},
+	})
+	require.NoError(t, err)
+
+	// 前缀按路径段匹配
+	cases := map[string]bool{
+		"https://svc.corp/api":                     true,
+		"https://svc.corp/api/users":               true,
+		"https://svc.corp/public/logo.png":         true,
+		"https://svc.corp/api/administrator":       true,
+		"https://svc.corp/api-internal/users":      false,
+		"https://svc.corp/publicity":               false,
+		"https://svc.corp/api/admin":               false,
+		"https://svc.corp/api/admin/users":         false,
+		"https://svc.corp/api/x/%2e%2e/admin/user": false,
+		"https://svc.corp/api/x/..%2fadmin":        false,
+	}
+	for raw, allowed := range cases {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		err = guard.CheckURL(u)
+		if allowed {
+			require.NoError(t, err, raw)
+		} else {
+			_, ok := v1.AsHTTPGuardError(err)
+			require.True(t, ok, raw)
+		}
+	}
+}
+
+func TestHandleHTTPRequest_Guard(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL+"/secret", http.StatusFound))
+	defer redirect.Close()
+
+	redirectURL, _ := url.Parse(redirect.URL)
+	redirectPort, _ := strconv.Atoi(redirectURL.Port())
+
+	defer viper.Reset()
+
+	// 只允许访问重定向服务的端口，跳转到其它端口时被拒绝
+	viper.Set("auth.http.allow_ports", []int{redirectPort})
+	require.NoError(t, v1.LoadHTTPGuard())
+	resp, err := v1.HandleHTTPRequest(v1.HTTPRequest{Method: "GET", URL: redirect.URL})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Contains(t, resp.Body, "request_denied")
+
+	// DNS 解析后的地址同样需要校验
+	viper.Reset()
+	viper.Set("auth.http.deny_cidrs", []string{"127.0.0.0/8", "::1/128"})
+	require.NoError(t, v1.LoadHTTPGuard())
+	resp, err = v1.HandleHTTPRequest(v1.HTTPRequest{Method: "GET", URL: "http://localhost:" + redirectURL.Port()})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	viper.Reset()
+	require.NoError(t, v1.LoadHTTPGuard())
+	resp, err = v1.HandleHTTPRequest(v1.HTTPRequest{Method: "GET", URL: redirect.URL})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "ok", resp.Body)
+}
+
+func TestHTTPGuard_DefaultDeny(t *testing.T) {
+	defer viper.Reset()
+	metadata := net.ParseIP("169.254.169.254")
+
+	// 配置了其它网段时默认网段仍然生效
+	viper.Set("auth.http.deny_cidrs", []string{"10.0.0.0/8"})
+	require.NoError(t, v1.LoadHTTPGuard())
+	require.Error(t, v1.GetHTTPGuard().CheckIP(metadata, "metadata"))
+	require.Error(t, v1.GetHTTPGuard().CheckIP(net.ParseIP("10.1.2.3"), "intranet"))
+
+	viper.Set("auth.http.disable_default_deny", true)
+	require.NoError(t, v1.LoadHTTPGuard())
+	require.NoError(t, v1.GetHTTPGuard().CheckIP(metadata, "metadata"))
+	require.Error(t, v1.GetHTTPGuard().CheckIP(net.ParseIP("10.1.2.3"), "intranet"))
+
+	viper.Reset()
+	require.NoError(t, v1.LoadHTTPGuard())
+}
@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+)
+
+const (
+	// DefaultMaxResponseSize 未配置时的响应体大小上限
+	DefaultMaxResponseSize = 10 << 20
+	// DefaultPreviewSize 预览模式默认返回的字节数
+	DefaultPreviewSize = 4096
+	// ResponseModePreview 只返回响应头和响应体的前 N 个字节
+	ResponseModePreview = "preview"
+)
+
+// responseLimit 计算本次请求的响应体读取上限，请求只能调小上游配置的上限
+func (u *HTTPUpstream) responseLimit(r *HTTPRequest) int64 {
+	limit := u.conf.MaxResponseSize
+	if limit <= 0 {
+		limit = DefaultMaxResponseSize
+	}
+	if r.MaxResponseSize > 0 && r.MaxResponseSize < limit {
+		limit = r.MaxResponseSize
+	}
+	if strings.EqualFold(r.ResponseMode, ResponseModePreview) {
+		preview := int64(r.PreviewSize)
+		if preview <= 0 {
+			preview = DefaultPreviewSize
+		}
+		limit = min(limit, preview)
+	}
+	return limit
+}
+
+// ReadResponse 按上限读取响应体，超过上限时截断并返回 truncated
+func (u *HTTPUpstream) ReadResponse(resp *http.Response, r *HTTPRequest) ([]byte, bool, error) {
+	decompress := r.Decompress == nil || *r.Decompress
+	body, truncated, err := readResponseBody(resp, u.responseLimit(r), decompress)
+	if truncated {
+		entry := logger.Log1.
+			WithField("configKey", u.conf.ConfigKey).
+			WithField("url", resp.Request.URL.Redacted()).
+			WithField("size", len(body))
+		if strings.EqualFold(r.ResponseMode, ResponseModePreview) {
+			entry.Debug("HTTP 响应体已按预览模式截断")
+		} else {
+			entry.Warn("HTTP 响应体超过大小上限，已截断")
+		}
+	}
+	return body, truncated, err
+}
+
+func readResponseBody(resp *http.Response, limit int64, decompress bool) ([]byte, bool, error) {
+	reader := io.Reader(resp.Body)
+	if decompress {
+		decoded, err := decodeContentEncoding(resp)
+		if err != nil {
+			return nil, false, err
+		}
+		reader = decoded
+	}
+	// 多读一个字节用于判断是否超过上限，解压后的大小同样受限
+	body, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(body)) > limit {
+		return body[:limit], true, nil
+	}
+	return body, false, nil
+}
+
+// decodeContentEncoding 解压 Transport 没有自动处理的 gzip/deflate 响应
+// (请求中显式设置了 Accept-Encoding 时 Transport 不会解压)
+func decodeContentEncoding(resp *http.Response) (io.Reader, error) {
+	var reader io.Reader
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "gzip", "x-gzip", "deflate":
+	default:
+		return resp.Body, nil
+	}
+	// 204/304 或 HEAD 等响应没有响应体，无需解压
+	br := bufio.NewReader(resp.Body)
+	if _, err := br.Peek(1); err == io.EOF {
+		return br, nil
+	}
+	switch encoding {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("解压 gzip 响应失败: %w", err)
+		}
+		reader = gz
+	case "deflate":
+		// 规范要求 zlib 格式，但也有服务端直接返回原始 deflate 数据
+		header, _ := br.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("解压 deflate 响应失败: %w", err)
+			}
+			reader = zr
+		} else {
+			reader = flate.NewReader(br)
+		}
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return reader, nil
+}
+
+// ResponseEncoding 返回响应体的编码，未解压的压缩内容只能以 base64 返回
+func ResponseEncoding(resp *http.Response, requested string) string {
+	if requested != "" {
+		return requested
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" && !strings.EqualFold(ce, "identity") {
+		return BodyEncodingBase64
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPRetryConfig 上游的重试策略
+type HTTPRetryConfig struct {
+	// 最大尝试次数 (包含第一次请求)，小于等于 1 表示不重试
+	MaxAttempts int `json:"max_attempts,omitempty" mapstructure:"max_attempts,omitempty"`
+	// 首次重试前的等待时间，单位毫秒，默认 200
+	InitialBackoff int `json:"initial_backoff,omitempty" mapstructure:"initial_backoff,omitempty"`
+	// 最长等待时间，单位毫秒，默认 5000
+	MaxBackoff int `json:"max_backoff,omitempty" mapstructure:"max_backoff,omitempty"`
+	// 等待时间的增长倍数，默认 2
+	Multiplier float64 `json:"multiplier,omitempty" mapstructure:"multiplier,omitempty"`
+	// 等待时间的随机抖动比例 0~1，默认 0.2
+	Jitter *float64 `json:"jitter,omitempty" mapstructure:"jitter,omitempty"`
+	// 需要重试的响应状态码，默认 502、503、504
+	RetryOnStatus []int `json:"retry_on_status,omitempty" mapstructure:"retry_on_status,omitempty"`
+	// 网络错误时是否重试，默认 true
+	RetryOnNetworkError *bool `json:"retry_on_network_error,omitempty" mapstructure:"retry_on_network_error,omitempty"`
+	// 是否重试非幂等请求 (POST、PATCH)，默认只重试幂等请求或带 Idempotency-Key 的请求
+	RetryNonIdempotent bool `json:"retry_non_idempotent,omitempty" mapstructure:"retry_non_idempotent,omitempty"`
+}
+
+type retryPolicy struct {
+	maxAttempts        int
+	initialBackoff     time.Duration
+	maxBackoff         time.Duration
+	multiplier         float64
+	jitter             float64
+	retryOnStatus      map[int]bool
+	retryOnNetwork     bool
+	retryNonIdempotent bool
+}
+
+func newRetryPolicy(conf *HTTPRetryConfig) *retryPolicy {
+	p := &retryPolicy{
+		maxAttempts:        conf.MaxAttempts,
+		initialBackoff:     time.Duration(conf.InitialBackoff) * time.Millisecond,
+		maxBackoff:         time.Duration(conf.MaxBackoff) * time.Millisecond,
+		multiplier:         conf.Multiplier,
+		jitter:             0.2,
+		retryOnStatus:      make(map[int]bool),
+		retryOnNetwork:     true,
+		retryNonIdempotent: conf.RetryNonIdempotent,
+	}
+	if p.maxAttempts < 1 {
+		p.maxAttempts = 1
+	}
+	if p.initialBackoff <= 0 {
+		p.initialBackoff = 200 * time.Millisecond
+	}
+	if p.maxBackoff <= 0 {
+		p.maxBackoff = 5 * time.Second
+	}
+	if p.multiplier < 1 {
+		p.multiplier = 2
+	}
+	if conf.Jitter != nil {
+		p.jitter = math.Min(math.Max(*conf.Jitter, 0), 1)
+	}
+	if conf.RetryOnNetworkError != nil {
+		p.retryOnNetwork = *conf.RetryOnNetworkError
+	}
+	statuses := conf.RetryOnStatus
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	for _, code := range statuses {
+		p.retryOnStatus[code] = true
+	}
+	return p
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// shouldRetry 判断第 attempt 次请求的结果是否需要重试
+func (p *retryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) bool {
+	if attempt >= p.maxAttempts {
+		return false
+	}
+	if !p.retryNonIdempotent && !isIdempotent(req) {
+		return false
+	}
+	// 请求体无法重放
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		// 调用方取消或超时、被访问控制拒绝、熔断时都不重试
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		if _, ok := AsHTTPErrorResponse(err); ok {
+			return false
+		}
+		return p.retryOnNetwork
+	}
+	return p.retryOnStatus[resp.StatusCode]
+}
+
+// backoff 计算第 attempt 次重试前的等待时间，带随机抖动，并参考 Retry-After
+func (p *retryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	d := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(attempt-1))
+	if p.jitter > 0 {
+		d += d * p.jitter * (rand.Float64()*2 - 1)
+	}
+	wait := time.Duration(math.Min(d, float64(p.maxBackoff)))
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if retryAfter := time.Duration(seconds) * time.Second; retryAfter > wait {
+				wait = min(retryAfter, p.maxBackoff)
+			}
+		}
+	}
+	return wait
+}
+
+// sleepContext 等待指定时间，调用方取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
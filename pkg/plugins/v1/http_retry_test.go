@@ -0,0 +1,157 @@
+package v1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPUpstream_Retry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 前两次请求返回 503
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{
+		ConfigKey: "flaky",
+		BaseURL:   server.URL,
+		Retry:     v1.HTTPRetryConfig{MaxAttempts: 3, InitialBackoff: 1, MaxBackoff: 10},
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := u.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// 默认不重试非幂等请求
+	atomic.StoreInt32(&calls, 0)
+	req, err = http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"a":1}`))
+	require.NoError(t, err)
+	resp, err = u.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// 带 Idempotency-Key 的请求可以重试，请求体会被重放
+	atomic.StoreInt32(&calls, 0)
+	req, err = http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"a":1}`))
+	require.NoError(t, err)
+	req.Header.Set("Idempotency-Key", "order-1")
+	resp, err = u.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestHandleHTTPRequest_DefaultRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	defer func() {
+		viper.Reset()
+		require.NoError(t, v1.LoadHTTPDefault())
+	}()
+
+	// 未配置 http_default.retry 时只请求一次
+	viper.Reset()
+	require.NoError(t, v1.LoadHTTPDefault())
+	resp, err := v1.HandleHTTPRequest(v1.HTTPRequest{Method: "GET", URL: server.URL})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	viper.Set("http_default.retry", map[string]interface{}{"max_attempts": 3, "initial_backoff": 1, "max_backoff": 10})
+	require.NoError(t, v1.LoadHTTPDefault())
+	resp, err = v1.HandleHTTPRequest(v1.HTTPRequest{Method: "GET", URL: server.URL})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestHTTPUpstream_CircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{
+		ConfigKey:      "unstable",
+		BaseURL:        server.URL,
+		CircuitBreaker: v1.HTTPCircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: 50},
+	})
+	require.NoError(t, err)
+
+	get := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := u.Do(req)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := get()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	// 熔断后快速失败，不再请求上游
+	_, err = get()
+	require.Error(t, err)
+	errResp, ok := v1.AsHTTPErrorResponse(err)
+	require.True(t, ok)
+	require.Equal(t, http.StatusServiceUnavailable, errResp.StatusCode)
+	require.NotEmpty(t, errResp.Header["Retry-After"])
+	var content map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(errResp.Body), &content))
+	require.Equal(t, "circuit_open", content["error"])
+	require.Equal(t, "unstable", content["upstream"])
+	require.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	// 超时后进入半开状态，探测成功则恢复
+	time.Sleep(60 * time.Millisecond)
+	healthy.Store(true)
+	resp, err := get()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = get()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 4, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,235 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+)
+
+// HTTPLoginConfig 表单登录请求
+type HTTPLoginConfig struct {
+	// 登录地址，可以是相对 base_url 的路径
+	URL string `json:"url,omitempty" mapstructure:"url,omitempty"`
+	// 请求方法，默认 POST
+	Method string `json:"method,omitempty" mapstructure:"method,omitempty"`
+	// 表单字段，以 application/x-www-form-urlencoded 提交
+	Form    map[string]string `json:"form,omitempty" mapstructure:"form,omitempty"`
+	Headers map[string]string `json:"headers,omitempty" mapstructure:"headers,omitempty"`
+	// 登录成功的条件，同时配置时需要全部满足；默认状态码小于 400 即成功
+	SuccessStatus       []int  `json:"success_status,omitempty" mapstructure:"success_status,omitempty"`
+	SuccessCookie       string `json:"success_cookie,omitempty" mapstructure:"success_cookie,omitempty"`
+	SuccessBodyContains string `json:"success_body_contains,omitempty" mapstructure:"success_body_contains,omitempty"`
+}
+
+// HTTPSessionExpiredConfig 判断会话过期的条件，满足任意一条即视为过期
+type HTTPSessionExpiredConfig struct {
+	Status []int `json:"status,omitempty" mapstructure:"status,omitempty"`
+	// 重定向后的地址包含该字符串，例如跳转到了登录页
+	URLContains  string `json:"url_contains,omitempty" mapstructure:"url_contains,omitempty"`
+	BodyContains string `json:"body_contains,omitempty" mapstructure:"body_contains,omitempty"`
+}
+
+// HTTPSessionConfig 基于 Cookie 的会话 plugins.http[].session
+type HTTPSessionConfig struct {
+	// 启用 Cookie，配置了 login 时自动启用
+	CookieJar bool                     `json:"cookie_jar,omitempty" mapstructure:"cookie_jar,omitempty"`
+	Login     HTTPLoginConfig          `json:"login,omitempty" mapstructure:"login,omitempty"`
+	Expired   HTTPSessionExpiredConfig `json:"expired,omitempty" mapstructure:"expired,omitempty"`
+}
+
+// 检查响应体是否包含过期标识时最多读取的字节数
+const sessionPeekSize = 64 << 10
+
+// sessionJar 可以清空的 CookieJar，重新登录前丢弃旧的会话 Cookie
+type sessionJar struct {
+	mu  sync.RWMutex
+	jar *cookiejar.Jar
+}
+
+func newSessionJar() *sessionJar {
+	jar, _ := cookiejar.New(nil)
+	return &sessionJar{jar: jar}
+}
+
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	j.jar.SetCookies(u, cookies)
+}
+
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.jar.Cookies(u)
+}
+
+func (j *sessionJar) reset() {
+	jar, _ := cookiejar.New(nil)
+	j.mu.Lock()
+	j.jar = jar
+	j.mu.Unlock()
+}
+
+// httpSession 按需登录并在会话过期后重新登录
+type httpSession struct {
+	configKey string
+	conf      HTTPSessionConfig
+	loginURL  string
+	client    *http.Client
+	jar       *sessionJar
+
+	mu       sync.Mutex
+	loggedIn bool
+	loginAt  time.Time
+}
+
+func newHTTPSession(u *HTTPUpstream, conf *HTTPSessionConfig) (*httpSession, error) {
+	if !conf.CookieJar && conf.Login.URL == "" {
+		return nil, nil
+	}
+	s := &httpSession{
+		configKey: u.conf.ConfigKey,
+		conf:      *conf,
+		client:    u.client,
+		jar:       newSessionJar(),
+	}
+	u.client.Jar = s.jar
+	if conf.Login.URL != "" {
+		loginURL, err := u.resolveURL(conf.Login.URL, false)
+		if err != nil {
+			return nil, fmt.Errorf("无效的登录地址: %w", err)
+		}
+		s.loginURL = loginURL
+	}
+	return s, nil
+}
+
+// ensure 尚未登录时执行登录，并发请求共用同一次登录
+func (s *httpSession) ensure(ctx context.Context) error {
+	if s.loginURL == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loggedIn {
+		return nil
+	}
+	if err := s.login(ctx); err != nil {
+		return err
+	}
+	s.loggedIn = true
+	s.loginAt = time.Now()
+	logger.Log1.WithField("configKey", s.configKey).Info("HTTP 上游登录成功")
+	return nil
+}
+
+// invalidate 标记会话过期；since 之后已经重新登录过则忽略，避免并发请求重复登录
+func (s *httpSession) invalidate(since time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loggedIn || s.loginAt.After(since) {
+		return
+	}
+	s.loggedIn = false
+	s.jar.reset()
+	logger.Log1.WithField("configKey", s.configKey).Warn("HTTP 上游会话已过期，将重新登录")
+}
+
+func (s *httpSession) login(ctx context.Context) error {
+	conf := &s.conf.Login
+	method := conf.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	form := url.Values{}
+	for k, v := range conf.Form {
+		form.Set(k, v)
+	}
+
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequestWithContext(ctx, method, s.loginURL, nil)
+		if err == nil && len(form) > 0 {
+			req.URL.RawQuery = form.Encode()
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, s.loginURL, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for k, v := range conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("上游 %s 登录请求失败: %w", s.configKey, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, sessionPeekSize))
+	if err != nil {
+		return fmt.Errorf("上游 %s 登录请求失败: %w", s.configKey, err)
+	}
+
+	if len(conf.SuccessStatus) > 0 {
+		if !slices.Contains(conf.SuccessStatus, resp.StatusCode) {
+			return fmt.Errorf("上游 %s 登录失败: 状态码 %d", s.configKey, resp.StatusCode)
+		}
+	} else if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("上游 %s 登录失败: 状态码 %d", s.configKey, resp.StatusCode)
+	}
+	if conf.SuccessCookie != "" && !s.hasCookie(req.URL, conf.SuccessCookie) {
+		return fmt.Errorf("上游 %s 登录失败: 未返回 Cookie %s", s.configKey, conf.SuccessCookie)
+	}
+	if conf.SuccessBodyContains != "" && !bytes.Contains(body, []byte(conf.SuccessBodyContains)) {
+		return fmt.Errorf("上游 %s 登录失败: 响应中未包含登录成功标识", s.configKey)
+	}
+	return nil
+}
+
+func (s *httpSession) hasCookie(u *url.URL, name string) bool {
+	for _, c := range s.jar.Cookies(u) {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// expired 判断响应是否表示会话已过期，需要时预读响应体并保持响应体可以继续读取
+func (s *httpSession) expired(resp *http.Response) bool {
+	if s.loginURL == "" {
+		return false
+	}
+	conf := &s.conf.Expired
+	if slices.Contains(conf.Status, resp.StatusCode) {
+		return true
+	}
+	if conf.URLContains != "" && resp.Request != nil && strings.Contains(resp.Request.URL.String(), conf.URLContains) {
+		return true
+	}
+	if conf.BodyContains != "" {
+		head, err := io.ReadAll(io.LimitReader(resp.Body, sessionPeekSize))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+		return err == nil && bytes.Contains(head, []byte(conf.BodyContains))
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package v1_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyApp 模拟只支持表单登录的老系统，未登录时跳转到登录页
+type legacyApp struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	logins   int32
+}
+
+func (a *legacyApp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/login":
+		if r.Method == http.MethodGet {
+			w.Write([]byte("请先登录"))
+			return
+		}
+		if r.FormValue("username") != "admin" || r.FormValue("password") != "secret" {
+			w.Write([]byte("用户名或密码错误"))
+			return
+		}
+		sid := fmt.Sprintf("s%d", atomic.AddInt32(&a.logins, 1))
+		a.mu.Lock()
+		a.sessions[sid] = true
+		a.mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "SID", Value: sid, Path: "/"})
+		http.Redirect(w, r, "/home", http.StatusFound)
+	case "/home":
+		w.Write([]byte("欢迎"))
+	default:
+		cookie, err := r.Cookie("SID")
+		a.mu.Lock()
+		valid := err == nil && a.sessions[cookie.Value]
+		a.mu.Unlock()
+		if !valid {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		w.Write([]byte("orders of " + cookie.Value))
+	}
+}
+
+// expireAll 服务端会话全部过期
+func (a *legacyApp) expireAll() {
+	a.mu.Lock()
+	a.sessions = map[string]bool{}
+	a.mu.Unlock()
+}
+
+func TestHTTPUpstream_Session(t *testing.T) {
+	app := &legacyApp{sessions: map[string]bool{}}
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	newUpstream := func(password string) *v1.HTTPUpstream {
+		u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{
+			ConfigKey: "legacy",
+			BaseURL:   server.URL,
+			Session: v1.HTTPSessionConfig{
+				Login: v1.HTTPLoginConfig{
+					URL:                 "/login",
+					Form:                map[string]string{"username": "admin", "password": password},
+					SuccessCookie:       "SID",
+					SuccessBodyContains: "欢迎",
+				},
+				Expired: v1.HTTPSessionExpiredConfig{URLContains: "/login"},
+			},
+		})
+		require.NoError(t, err)
+		return u
+	}
+	get := func(u *v1.HTTPUpstream) (string, error) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/orders", nil)
+		require.NoError(t, err)
+		resp, err := u.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	u := newUpstream("secret")
+	body, err := get(u)
+	require.NoError(t, err)
+	require.Equal(t, "orders of s1", body)
+
+	// 会话 Cookie 在请求之间复用
+	body, err = get(u)
+	require.NoError(t, err)
+	require.Equal(t, "orders of s1", body)
+	require.EqualValues(t, 1, atomic.LoadInt32(&app.logins))
+
+	// 会话过期后自动重新登录
+	app.expireAll()
+	body, err = get(u)
+	require.NoError(t, err)
+	require.Equal(t, "orders of s2", body)
+	require.EqualValues(t, 2, atomic.LoadInt32(&app.logins))
+
+	_, err = get(newUpstream("wrong"))
+	require.ErrorContains(t, err, "登录失败")
+}
@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// HTTPTLSConfig 上游的 TLS 配置
+type HTTPTLSConfig struct {
+	// 额外信任的 CA 证书 (PEM)，与系统证书一起使用
+	CAFile string `json:"ca_file,omitempty" mapstructure:"ca_file,omitempty"`
+	// 双向 TLS 使用的客户端证书和私钥 (PEM)
+	CertFile string `json:"cert_file,omitempty" mapstructure:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty" mapstructure:"key_file,omitempty"`
+	// 最低 TLS 版本: 1.0 | 1.1 | 1.2 (默认) | 1.3
+	MinVersion string `json:"min_version,omitempty" mapstructure:"min_version,omitempty"`
+	// 覆盖 SNI 以及证书校验使用的服务名
+	ServerName string `json:"server_name,omitempty" mapstructure:"server_name,omitempty"`
+	// 跳过证书校验，仅用于排查问题
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" mapstructure:"insecure_skip_verify,omitempty"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// IsZero 是否未配置任何 TLS 选项
+func (c *HTTPTLSConfig) IsZero() bool {
+	return *c == HTTPTLSConfig{}
+}
+
+// Build 根据配置生成 tls.Config
+func (c *HTTPTLSConfig) Build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("不支持的 TLS 版本: %s", c.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 文件失败: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 文件中没有有效的证书: %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("cert_file 和 key_file 需要同时配置")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	tlsConfig.InsecureSkipVerify = c.InsecureSkipVerify
+	return tlsConfig, nil
+}
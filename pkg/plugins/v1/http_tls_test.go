@@ -0,0 +1,95 @@
+package v1_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+// newClientCert 生成自签名的客户端证书
+func newClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ipaas-agent"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDER), cert
+}
+
+func TestHTTPUpstream_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := newClientCert(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	get := func(conf v1.HTTPTLSConfig) (*http.Response, error) {
+		u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{ConfigKey: "mtls", BaseURL: server.URL, TLS: conf})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		return u.Do(req)
+	}
+
+	// 不信任自签名的服务端证书
+	_, err := get(v1.HTTPTLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.Error(t, err)
+
+	// 未提供客户端证书
+	_, err = get(v1.HTTPTLSConfig{CAFile: caFile})
+	require.Error(t, err)
+
+	resp, err := get(v1.HTTPTLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2"})
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = get(v1.HTTPTLSConfig{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{ConfigKey: "bad", TLS: v1.HTTPTLSConfig{MinVersion: "1.4"}})
+	require.Error(t, err)
+	_, err = v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{ConfigKey: "bad", TLS: v1.HTTPTLSConfig{CertFile: certFile}})
+	require.Error(t, err)
+}
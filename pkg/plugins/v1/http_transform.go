@@ -0,0 +1,184 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/clbanning/mxj/v2"
+	"github.com/jmespath/go-jmespath"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+)
+
+// HTTPHeaderTransform 设置或删除请求头、响应头
+type HTTPHeaderTransform struct {
+	Set    map[string]string `json:"set,omitempty" mapstructure:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty" mapstructure:"remove,omitempty"`
+}
+
+// HTTPRequestTransform 发送请求前的转换
+type HTTPRequestTransform struct {
+	Headers HTTPHeaderTransform `json:"headers,omitempty" mapstructure:"headers,omitempty"`
+	// 请求体模板 (text/template)，可以引用 .vars .body .headers .method .url
+	BodyTemplate string `json:"body_template,omitempty" mapstructure:"body_template,omitempty"`
+	// 将 JSON 请求体转换为 XML
+	JSONToXML bool `json:"json_to_xml,omitempty" mapstructure:"json_to_xml,omitempty"`
+	// XML 根节点名称，不配置时 JSON 只有一个键则以该键为根节点
+	XMLRoot string `json:"xml_root,omitempty" mapstructure:"xml_root,omitempty"`
+}
+
+// HTTPResponseTransform 返回响应前的转换
+type HTTPResponseTransform struct {
+	Headers HTTPHeaderTransform `json:"headers,omitempty" mapstructure:"headers,omitempty"`
+	// 将 XML 响应体转换为 JSON
+	XMLToJSON bool `json:"xml_to_json,omitempty" mapstructure:"xml_to_json,omitempty"`
+	// 使用 JMESPath 表达式提取响应字段
+	Extract string `json:"extract,omitempty" mapstructure:"extract,omitempty"`
+}
+
+// HTTPTransformConfig 上游的请求/响应转换 plugins.http[].transform
+type HTTPTransformConfig struct {
+	Request  HTTPRequestTransform  `json:"request,omitempty" mapstructure:"request,omitempty"`
+	Response HTTPResponseTransform `json:"response,omitempty" mapstructure:"response,omitempty"`
+}
+
+// httpTransformer 预编译的转换规则
+type httpTransformer struct {
+	conf    HTTPTransformConfig
+	tmpl    *template.Template
+	extract *jmespath.JMESPath
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func newHTTPTransformer(conf *HTTPTransformConfig) (*httpTransformer, error) {
+	t := &httpTransformer{conf: *conf}
+	if conf.Request.BodyTemplate != "" {
+		tmpl, err := template.New("body").Funcs(templateFuncs).Option("missingkey=zero").Parse(conf.Request.BodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("请求体模板错误: %w", err)
+		}
+		t.tmpl = tmpl
+	}
+	if conf.Response.Extract != "" {
+		extract, err := jmespath.Compile(conf.Response.Extract)
+		if err != nil {
+			return nil, fmt.Errorf("JMESPath 表达式 %q 错误: %w", conf.Response.Extract, err)
+		}
+		t.extract = extract
+	}
+	return t, nil
+}
+
+func (h *HTTPHeaderTransform) apply(header http.Header) {
+	for _, k := range h.Remove {
+		header.Del(k)
+	}
+	for k, v := range h.Set {
+		header.Set(k, v)
+	}
+}
+
+// TransformRequestBody 按上游配置渲染请求体模板、转换请求体格式，
+// 返回新的请求体以及需要覆盖的 Content-Type
+func (u *HTTPUpstream) TransformRequestBody(r *HTTPRequest, body []byte) ([]byte, string, error) {
+	t := u.transform
+	if t == nil {
+		return body, "", nil
+	}
+	var contentType string
+	if t.tmpl != nil {
+		var parsed interface{}
+		if json.Unmarshal(body, &parsed) != nil {
+			parsed = string(body)
+		}
+		var buf bytes.Buffer
+		err := t.tmpl.Execute(&buf, map[string]interface{}{
+			"vars":    r.Variables,
+			"body":    parsed,
+			"headers": r.Headers,
+			"method":  r.Method,
+			"url":     r.URL,
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("渲染请求体模板失败: %w", err)
+		}
+		body = buf.Bytes()
+	}
+	if t.conf.Request.JSONToXML && len(body) > 0 {
+		m, err := mxj.NewMapJson(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("请求体不是有效的 JSON 对象: %w", err)
+		}
+		if t.conf.Request.XMLRoot != "" {
+			body, err = m.Xml(t.conf.Request.XMLRoot)
+		} else {
+			body, err = m.Xml()
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("请求体转换为 XML 失败: %w", err)
+		}
+		contentType = "application/xml"
+	}
+	return body, contentType, nil
+}
+
+// ExtractsResponse 是否配置了响应字段提取，提取结果可能不是 JSON 对象
+func (u *HTTPUpstream) ExtractsResponse() bool {
+	return u.transform != nil && u.transform.extract != nil
+}
+
+// TransformResponse 按上游配置修改响应头、转换响应体，转换失败时保留原始响应体
+func (u *HTTPUpstream) TransformResponse(header http.Header, body []byte) []byte {
+	t := u.transform
+	if t == nil {
+		return body
+	}
+	t.conf.Response.Headers.apply(header)
+
+	entry := logger.Log1.WithField("configKey", u.conf.ConfigKey)
+	if t.conf.Response.XMLToJSON && len(body) > 0 {
+		m, err := mxj.NewMapXml(body)
+		if err == nil {
+			var converted []byte
+			if converted, err = m.Json(); err == nil {
+				body = converted
+				header.Set("Content-Type", "application/json")
+				header.Del("Content-Length")
+			}
+		}
+		if err != nil {
+			entry.WithField("error", err).Warn("响应体转换为 JSON 失败，返回原始内容")
+			return body
+		}
+	}
+	if t.extract != nil {
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			entry.WithField("error", err).Warn("响应体不是 JSON，跳过字段提取")
+			return body
+		}
+		result, err := t.extract.Search(data)
+		if err != nil {
+			entry.WithField("error", err).Warn("提取响应字段失败，返回原始内容")
+			return body
+		}
+		extracted, err := json.Marshal(result)
+		if err != nil {
+			entry.WithField("error", err).Warn("提取响应字段失败，返回原始内容")
+			return body
+		}
+		body = extracted
+		header.Set("Content-Type", "application/json")
+		header.Del("Content-Length")
+	}
+	return body
+}
@@ -0,0 +1,161 @@
+package v1
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+)
+
+// ProxyDirect 不使用代理，忽略环境变量
+const ProxyDirect = "direct"
+
+// HTTPTransportConfig 上游的连接池、超时和代理配置，时间单位均为毫秒
+type HTTPTransportConfig struct {
+	// 空闲连接总数上限，默认 100
+	MaxIdleConns int `json:"max_idle_conns,omitempty" mapstructure:"max_idle_conns,omitempty"`
+	// 每个主机的空闲连接上限，默认 10
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host,omitempty" mapstructure:"max_idle_conns_per_host,omitempty"`
+	// 每个主机的连接数上限，默认不限制
+	MaxConnsPerHost int `json:"max_conns_per_host,omitempty" mapstructure:"max_conns_per_host,omitempty"`
+	// 空闲连接保留时间，默认 90000
+	IdleConnTimeout int `json:"idle_conn_timeout,omitempty" mapstructure:"idle_conn_timeout,omitempty"`
+	// 关闭连接复用
+	DisableKeepAlives bool `json:"disable_keep_alives,omitempty" mapstructure:"disable_keep_alives,omitempty"`
+	// 是否尝试 HTTP/2，默认 true
+	HTTP2 *bool `json:"http2,omitempty" mapstructure:"http2,omitempty"`
+	// 建立 TCP 连接的超时时间，默认 30000
+	DialTimeout int `json:"dial_timeout,omitempty" mapstructure:"dial_timeout,omitempty"`
+	// TCP keep-alive 探测间隔，默认 30000
+	KeepAlive int `json:"keep_alive,omitempty" mapstructure:"keep_alive,omitempty"`
+	// TLS 握手超时时间，默认 10000
+	TLSHandshakeTimeout int `json:"tls_handshake_timeout,omitempty" mapstructure:"tls_handshake_timeout,omitempty"`
+	// 发送请求后等待响应头的超时时间，默认不限制
+	ResponseHeaderTimeout int `json:"response_header_timeout,omitempty" mapstructure:"response_header_timeout,omitempty"`
+	// 代理地址，支持 http://、https://、socks5://；为空时使用 HTTP_PROXY 等环境变量，direct 表示不使用代理
+	Proxy string `json:"proxy,omitempty" mapstructure:"proxy,omitempty"`
+	// 不经过代理的主机，逗号分隔，语义与 NO_PROXY 环境变量相同
+	NoProxy string `json:"no_proxy,omitempty" mapstructure:"no_proxy,omitempty"`
+}
+
+func millis(ms int, def time.Duration) time.Duration {
+	if ms <= 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// proxyFunc 根据配置返回代理选择函数
+func (c *HTTPTransportConfig) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	switch strings.ToLower(strings.TrimSpace(c.Proxy)) {
+	case "":
+		if c.NoProxy == "" {
+			return http.ProxyFromEnvironment, nil
+		}
+		// 使用环境变量中的代理，但以配置的 no_proxy 为准
+		conf := httpproxy.FromEnvironment()
+		conf.NoProxy = c.NoProxy
+		return requestProxyFunc(conf.ProxyFunc()), nil
+	case ProxyDirect:
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(c.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("无效的代理地址 %q: %w", c.Proxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议 %q，仅支持 http、https、socks5", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("代理地址缺少主机: %s", c.Proxy)
+	}
+	conf := &httpproxy.Config{HTTPProxy: c.Proxy, HTTPSProxy: c.Proxy, NoProxy: c.NoProxy}
+	return requestProxyFunc(conf.ProxyFunc()), nil
+}
+
+func requestProxyFunc(f func(*url.URL) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		return f(req.URL)
+	}
+}
+
+var guardedTransport, _ = newGuardedTransport(nil, &HTTPTransportConfig{})
+
+// newGuardedTransport 创建经过访问控制的 Transport
+func newGuardedTransport(tlsConfig *tls.Config, conf *HTTPTransportConfig) (*http.Transport, error) {
+	proxy, err := conf.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &guardDialer{dialer: &net.Dialer{
+		Timeout:   millis(conf.DialTimeout, 30*time.Second),
+		KeepAlive: millis(conf.KeepAlive, 30*time.Second),
+	}}
+	t := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		MaxConnsPerHost:       conf.MaxConnsPerHost,
+		IdleConnTimeout:       millis(conf.IdleConnTimeout, 90*time.Second),
+		DisableKeepAlives:     conf.DisableKeepAlives,
+		TLSHandshakeTimeout:   millis(conf.TLSHandshakeTimeout, 10*time.Second),
+		ResponseHeaderTimeout: millis(conf.ResponseHeaderTimeout, 0),
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if proxy != nil {
+		t.Proxy = dialer.wrapProxy(proxy)
+	}
+	if conf.MaxIdleConns > 0 {
+		t.MaxIdleConns = conf.MaxIdleConns
+	}
+	if conf.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = conf.MaxIdleConnsPerHost
+	}
+	if conf.HTTP2 != nil && !*conf.HTTP2 {
+		// TLSNextProto 不为 nil 时不会协商 HTTP/2
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t, nil
+}
+
+type dialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// countConnections 统计 Transport 连接池中打开的连接数
+func countConnections(configKey string, dial dialContextFunc) dialContextFunc {
+	gauge := metrics.HTTPUpstreamOpenConnections.WithLabelValues(metrics.ConfigKey(configKey))
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		gauge.Inc()
+		return &countedConn{Conn: conn, gauge: gauge}, nil
+	}
+}
+
+type countedConn struct {
+	net.Conn
+	gauge prometheus.Gauge
+	once  sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(c.gauge.Dec)
+	return c.Conn.Close()
+}
@@ -0,0 +1,132 @@
+package v1_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+)
+
+func TestHTTPUpstream_Proxy(t *testing.T) {
+	var proxied int32
+	// 简单的正向代理，记录转发的目标地址
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&proxied, 1)
+		w.Write([]byte("via proxy " + r.URL.Host))
+	}))
+	defer proxy.Close()
+
+	newUpstream := func(conf v1.HTTPTransportConfig) *v1.HTTPUpstream {
+		u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{ConfigKey: "erp", BaseURL: "http://203.0.113.10", Transport: conf})
+		require.NoError(t, err)
+		return u
+	}
+	get := func(u *v1.HTTPUpstream) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, "http://203.0.113.10/ping", nil)
+		require.NoError(t, err)
+		return u.Do(req)
+	}
+
+	resp, err := get(newUpstream(v1.HTTPTransportConfig{Proxy: proxy.URL}))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 1, atomic.LoadInt32(&proxied))
+
+	// 命中 no_proxy 的主机直接连接
+	_, err = get(newUpstream(v1.HTTPTransportConfig{Proxy: proxy.URL, NoProxy: "203.0.113.0/24", DialTimeout: 100}))
+	require.Error(t, err)
+	require.EqualValues(t, 1, atomic.LoadInt32(&proxied))
+
+	_, err = v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{ConfigKey: "bad", Transport: v1.HTTPTransportConfig{Proxy: "ftp://proxy:21"}})
+	require.Error(t, err)
+
+	// 代理地址只对使用该代理的上游放行，其它上游直接访问时仍然校验网段
+	defer func() {
+		viper.Reset()
+		require.NoError(t, v1.LoadHTTPGuard())
+	}()
+	viper.Set("auth.http.deny_cidrs", []string{"127.0.0.0/8", "::1/128"})
+	require.NoError(t, v1.LoadHTTPGuard())
+	resp, err = get(newUpstream(v1.HTTPTransportConfig{Proxy: proxy.URL}))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 2, atomic.LoadInt32(&proxied))
+
+	direct, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{ConfigKey: "direct", BaseURL: proxy.URL, Transport: v1.HTTPTransportConfig{Proxy: v1.ProxyDirect}})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, proxy.URL+"/ping", nil)
+	require.NoError(t, err)
+	_, err = direct.Do(req)
+	_, ok := v1.AsHTTPGuardError(err)
+	require.True(t, ok, err)
+	require.EqualValues(t, 2, atomic.LoadInt32(&proxied))
+}
+
+func TestHTTPUpstream_HTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	proto := func(http2 bool) string {
+		u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{
+			ConfigKey: "h2",
+			BaseURL:   server.URL,
+			TLS:       v1.HTTPTLSConfig{InsecureSkipVerify: true},
+			Transport: v1.HTTPTransportConfig{HTTP2: &http2, MaxIdleConnsPerHost: 2},
+		})
+		require.NoError(t, err)
+		defer u.Close()
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := u.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.Proto
+	}
+
+	require.Equal(t, "HTTP/2.0", proto(true))
+	require.Equal(t, "HTTP/1.1", proto(false))
+}
+
+func TestHTTPUpstream_Metrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{ConfigKey: "metrics", BaseURL: server.URL})
+	require.NoError(t, err)
+	get := func(path string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := u.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	get("/ok")
+	get("/ok")
+	get("/missing")
+
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.HTTPUpstreamResponses.WithLabelValues("metrics", "200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPUpstreamResponses.WithLabelValues("metrics", "404")))
+	// 连接在请求之间复用
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPUpstreamOpenConnections.WithLabelValues("metrics")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPUpstreamConnections.WithLabelValues("metrics", "false")))
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.HTTPUpstreamConnections.WithLabelValues("metrics", "true")))
+
+	u.Close()
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.HTTPUpstreamOpenConnections.WithLabelValues("metrics")))
+}
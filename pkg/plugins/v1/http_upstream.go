@@ -0,0 +1,465 @@
+package v1
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/open-dingtalk/ipaas-agent/pkg/audit"
+	"github.com/open-dingtalk/ipaas-agent/pkg/logger"
+	"github.com/open-dingtalk/ipaas-agent/pkg/metrics"
+	"github.com/open-dingtalk/ipaas-agent/pkg/tracing"
+)
+
+// HTTPUpstreamConfig 命名的 HTTP 上游 plugins.http[]
+type HTTPUpstreamConfig struct {
+	ConfigKey string `json:"config_key,omitempty" mapstructure:"config_key,omitempty"`
+	// 上游的基础地址，请求中的 url 为相对路径
+	BaseURL string `json:"base_url,omitempty" mapstructure:"base_url,omitempty"`
+	// 每次请求附带的请求头
+	Headers map[string]string `json:"headers,omitempty" mapstructure:"headers,omitempty"`
+	// 默认超时时间，单位毫秒
+	Timeout int            `json:"timeout,omitempty" mapstructure:"timeout,omitempty"`
+	Auth    HTTPAuthConfig `json:"auth,omitempty" mapstructure:"auth,omitempty"`
+	TLS     HTTPTLSConfig  `json:"tls,omitempty" mapstructure:"tls,omitempty"`
+	// 响应体大小上限，单位字节，默认 10MB
+	MaxResponseSize int64 `json:"max_response_size,omitempty" mapstructure:"max_response_size,omitempty"`
+	// 连接池、超时和代理
+	Transport HTTPTransportConfig `json:"transport,omitempty" mapstructure:"transport,omitempty"`
+	// 基于 Cookie 的会话和表单登录
+	Session HTTPSessionConfig `json:"session,omitempty" mapstructure:"session,omitempty"`
+	// 请求/响应转换
+	Transform HTTPTransformConfig `json:"transform,omitempty" mapstructure:"transform,omitempty"`
+	// 重试和熔断
+	Retry          HTTPRetryConfig          `json:"retry,omitempty" mapstructure:"retry,omitempty"`
+	CircuitBreaker HTTPCircuitBreakerConfig `json:"circuit_breaker,omitempty" mapstructure:"circuit_breaker,omitempty"`
+}
+
+// HTTPUpstream 运行时的上游，持有客户端和鉴权状态
+type HTTPUpstream struct {
+	conf      HTTPUpstreamConfig
+	base      *url.URL
+	client    *http.Client
+	auth      httpAuthenticator
+	retry     *retryPolicy
+	breaker   *circuitBreaker
+	transform *httpTransformer
+	session   *httpSession
+}
+
+var (
+	httpUpstreams atomic.Pointer[map[string]*HTTPUpstream]
+	// 未指定 configKey 时使用的默认上游
+	defaultHTTPUpstream atomic.Pointer[HTTPUpstream]
+)
+
+func init() {
+	setDefaultHTTPUpstream(&HTTPRetryConfig{})
+}
+
+// HTTPDefaultConfig 未指定 configKey、直接使用完整 url 的请求的设置 http_default，
+// 命名上游使用各自的配置
+type HTTPDefaultConfig struct {
+	Retry HTTPRetryConfig `json:"retry,omitempty" mapstructure:"retry,omitempty"`
+}
+
+// LoadHTTPDefault 从配置文件 http_default 加载默认上游的重试策略
+func LoadHTTPDefault() error {
+	var conf HTTPDefaultConfig
+	if err := viper.UnmarshalKey("http_default", &conf); err != nil {
+		return fmt.Errorf("解析 HTTP 默认配置出错: %w", err)
+	}
+	setDefaultHTTPUpstream(&conf.Retry)
+	logger.Log1.WithField("重试策略", conf.Retry).Info("HTTP 默认配置已加载")
+	return nil
+}
+
+// setDefaultHTTPUpstream 设置默认上游的重试策略；
+// 默认上游可以访问任意主机，不启用熔断，避免一个主机的故障影响其它请求
+func setDefaultHTTPUpstream(retry *HTTPRetryConfig) {
+	defaultHTTPUpstream.Store(&HTTPUpstream{
+		conf:   HTTPUpstreamConfig{Retry: *retry},
+		client: httpClient,
+		retry:  newRetryPolicy(retry),
+	})
+}
+
+// NewHTTPUpstream 根据配置创建上游
+func NewHTTPUpstream(conf HTTPUpstreamConfig) (*HTTPUpstream, error) {
+	u := &HTTPUpstream{
+		conf:    conf,
+		client:  NewGuardedHTTPClient(0),
+		retry:   newRetryPolicy(&conf.Retry),
+		breaker: newCircuitBreaker(conf.ConfigKey, &conf.CircuitBreaker),
+	}
+	var tlsConfig *tls.Config
+	if !conf.TLS.IsZero() {
+		var err error
+		if tlsConfig, err = conf.TLS.Build(); err != nil {
+			return nil, fmt.Errorf("上游 %s TLS 配置错误: %w", conf.ConfigKey, err)
+		}
+		if tlsConfig.InsecureSkipVerify {
+			logger.Log1.WithField("configKey", conf.ConfigKey).Warn("上游已关闭 TLS 证书校验，连接可能被中间人攻击")
+		}
+	}
+	// 每个上游使用独立的 Transport，连接在请求之间复用
+	transport, err := newGuardedTransport(tlsConfig, &conf.Transport)
+	if err != nil {
+		return nil, fmt.Errorf("上游 %s 连接配置错误: %w", conf.ConfigKey, err)
+	}
+	transport.DialContext = countConnections(conf.ConfigKey, transport.DialContext)
+	u.client.Transport = transport
+	u.client.CheckRedirect = upstreamCheckRedirect
+	if conf.BaseURL != "" {
+		base, err := url.Parse(conf.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 base_url %q: %w", conf.BaseURL, err)
+		}
+		if base.Scheme == "" || base.Host == "" {
+			return nil, fmt.Errorf("base_url 必须是完整的地址: %s", conf.BaseURL)
+		}
+		u.base = base
+	}
+	if u.session, err = newHTTPSession(u, &conf.Session); err != nil {
+		return nil, fmt.Errorf("上游 %s 会话配置错误: %w", conf.ConfigKey, err)
+	}
+	if u.transform, err = newHTTPTransformer(&conf.Transform); err != nil {
+		return nil, fmt.Errorf("上游 %s 转换配置错误: %w", conf.ConfigKey, err)
+	}
+	auth, err := newHTTPAuthenticator(&conf.Auth, u.client)
+	if err != nil {
+		return nil, fmt.Errorf("上游 %s 鉴权配置错误: %w", conf.ConfigKey, err)
+	}
+	u.auth = auth
+	return u, nil
+}
+
+// upstreamCheckRedirect 请求中带有本地注入的请求头和凭证，不允许重定向到其它协议或主机
+func upstreamCheckRedirect(req *http.Request, via []*http.Request) error {
+	if origin := via[0].URL; req.URL.Scheme != origin.Scheme || req.URL.Host != origin.Host {
+		return &HTTPGuardError{Reason: "上游不允许重定向到其它主机", Target: req.URL.Redacted()}
+	}
+	return guardCheckRedirect(req, via)
+}
+
+// LoadHTTPUpstreams 从配置文件 plugins.http 加载命名上游
+func LoadHTTPUpstreams() error {
+	return ReloadHTTPUpstreams(nil)
+}
+
+// ReloadHTTPUpstreams 重新加载命名上游，affected 返回 false 的上游沿用原对象，
+// 保留已获取的 token、会话和熔断状态；affected 为 nil 时全部重建
+func ReloadHTTPUpstreams(affected func(configKey string) bool) error {
+	var configs []HTTPUpstreamConfig
+	if err := viper.UnmarshalKey("plugins.http", &configs); err != nil {
+		return fmt.Errorf("解析 HTTP 上游配置出错: %w", err)
+	}
+
+	var current map[string]*HTTPUpstream
+	if old := httpUpstreams.Load(); old != nil {
+		current = *old
+	}
+	upstreams := make(map[string]*HTTPUpstream, len(configs))
+	for _, conf := range configs {
+		if conf.ConfigKey == "" {
+			return fmt.Errorf("HTTP 上游缺少 config_key: %s", conf.BaseURL)
+		}
+		if u, ok := current[conf.ConfigKey]; ok && affected != nil && !affected(conf.ConfigKey) {
+			upstreams[conf.ConfigKey] = u
+			continue
+		}
+		u, err := NewHTTPUpstream(conf)
+		if err != nil {
+			return err
+		}
+		upstreams[conf.ConfigKey] = u
+		logger.Log1.
+			WithField("configKey", conf.ConfigKey).
+			WithField("baseURL", conf.BaseURL).
+			WithField("鉴权方式", conf.Auth.Type).
+			WithField("代理", conf.Transport.Proxy).
+			Info("HTTP 上游已加载")
+	}
+	if old := httpUpstreams.Swap(&upstreams); old != nil {
+		for key, u := range *old {
+			if upstreams[key] != u {
+				u.Close()
+			}
+		}
+	}
+	return nil
+}
+
+// Close 关闭上游的空闲连接，进行中的请求不受影响
+func (u *HTTPUpstream) Close() {
+	u.client.CloseIdleConnections()
+}
+
+// Ping 不带鉴权向 base_url 发送 HEAD 请求，收到任意响应即认为上游可以连接
+func (u *HTTPUpstream) Ping(ctx context.Context) error {
+	if u.base == nil {
+		return fmt.Errorf("上游 %s 未配置 base_url", u.conf.ConfigKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.base.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetHTTPUpstream 按 configKey 查找上游，configKey 为空时返回默认上游
+func GetHTTPUpstream(configKey string) (*HTTPUpstream, error) {
+	if configKey == "" {
+		return defaultHTTPUpstream.Load(), nil
+	}
+	if upstreams := httpUpstreams.Load(); upstreams != nil {
+		if u, ok := (*upstreams)[configKey]; ok {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("未找到 HTTP 上游配置: %s", configKey)
+}
+
+// HTTPUpstreamKeys 返回已加载的命名上游，按 configKey 排序
+func HTTPUpstreamKeys() []string {
+	upstreams := httpUpstreams.Load()
+	if upstreams == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(*upstreams))
+	for key := range *upstreams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ConfigKey 返回上游的引用键名
+func (u *HTTPUpstream) ConfigKey() string {
+	return u.conf.ConfigKey
+}
+
+// ResolveURL 将请求中的相对路径拼接到 base_url 上；
+// 命名上游不允许访问其它主机或 base_url 路径之外的地址，避免本地凭证泄露
+func (u *HTTPUpstream) ResolveURL(raw string) (string, error) {
+	return u.resolveURL(raw, true)
+}
+
+// resolveURL confined 为 false 时允许访问同一主机上 base_url 路径之外的地址，用于本地配置的登录地址
+func (u *HTTPUpstream) resolveURL(raw string, confined bool) (string, error) {
+	if u.base == nil {
+		return raw, nil
+	}
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if ref.IsAbs() || ref.Host != "" {
+		if ref.Scheme != u.base.Scheme || ref.Host != u.base.Host {
+			return "", fmt.Errorf("上游 %s 只能访问 %s", u.conf.ConfigKey, u.base.Host)
+		}
+		if confined {
+			if err := u.checkPath(ref.Path); err != nil {
+				return "", err
+			}
+		}
+		return ref.String(), nil
+	}
+	resolved := *u.base
+	// url 为空时直接访问 base_url，例如 GraphQL 端点
+	if ref.Path != "" {
+		// ref.Path 已解码，%2e%2e 与 .. 一样会被清理
+		joined := strings.TrimSuffix(u.base.Path, "/") + "/" + strings.TrimPrefix(ref.Path, "/")
+		cleaned := path.Clean(joined)
+		if strings.HasSuffix(joined, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		if confined {
+			if err := u.checkPath(cleaned); err != nil {
+				return "", err
+			}
+		}
+		resolved.Path = cleaned
+		resolved.RawPath = ""
+	}
+	if ref.RawQuery != "" {
+		if resolved.RawQuery != "" {
+			resolved.RawQuery += "&" + ref.RawQuery
+		} else {
+			resolved.RawQuery = ref.RawQuery
+		}
+	}
+	resolved.Fragment = ref.Fragment
+	return resolved.String(), nil
+}
+
+// checkPath 清理后的路径必须位于 base_url 的路径之下
+func (u *HTTPUpstream) checkPath(p string) error {
+	base := path.Clean("/" + u.base.Path)
+	cleaned := path.Clean("/" + p)
+	if base == "/" || cleaned == base || strings.HasPrefix(cleaned, base+"/") {
+		return nil
+	}
+	return fmt.Errorf("上游 %s 只能访问 %s 下的路径", u.conf.ConfigKey, u.base.Path)
+}
+
+// Timeout 请求未指定超时时使用上游配置
+func (u *HTTPUpstream) Timeout(requested time.Duration) time.Duration {
+	if requested > 0 {
+		return requested
+	}
+	return time.Duration(u.conf.Timeout) * time.Millisecond
+}
+
+// Do 注入本地请求头和凭证后发送请求，按上游配置重试和熔断
+func (u *HTTPUpstream) Do(req *http.Request) (*http.Response, error) {
+	for k, v := range u.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	if u.transform != nil {
+		u.transform.conf.Request.Headers.apply(req.Header)
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := u.attempt(req)
+		if !u.retry.shouldRetry(req, resp, err, attempt) {
+			return resp, err
+		}
+
+		wait := u.retry.backoff(attempt, resp)
+		entry := logger.Log1.
+			WithField("configKey", u.conf.ConfigKey).
+			WithField("attempt", attempt).
+			WithField("wait", wait.String())
+		if err != nil {
+			entry = entry.WithField("error", err)
+		} else {
+			entry = entry.WithField("status", resp.StatusCode)
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		entry.Warn("HTTP 请求失败，准备重试")
+
+		if err := sleepContext(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt 经过熔断器发送一次请求
+func (u *HTTPUpstream) attempt(req *http.Request) (*http.Response, error) {
+	if err := u.breaker.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := u.send(req)
+	u.breaker.record(resp, err)
+	metrics.HTTPUpstreamResponses.WithLabelValues(metrics.ConfigKey(u.conf.ConfigKey), metrics.StatusCode(resp, err)).Inc()
+	return resp, err
+}
+
+// cloneRequest 复制请求并重放请求体，保证每次尝试互不影响
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// send 发送请求，令牌失效或会话过期时刷新后重试一次
+func (u *HTTPUpstream) send(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := u.sendOnce(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// 请求体无法重放
+		return resp, nil
+	}
+
+	// OAuth2 令牌失效时刷新后重试
+	if invalidator, ok := u.auth.(tokenInvalidator); ok && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		invalidator.Invalidate()
+		return u.sendOnce(req)
+	}
+	// 会话过期时重新登录后重试
+	if u.session != nil && u.session.expired(resp) {
+		resp.Body.Close()
+		u.session.invalidate(start)
+		return u.sendOnce(req)
+	}
+	return resp, nil
+}
+
+// sendOnce 登录并注入凭证后发送一次请求
+func (u *HTTPUpstream) sendOnce(req *http.Request) (*http.Response, error) {
+	r, err := cloneRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if u.session != nil {
+		if err := u.session.ensure(r.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if u.auth != nil {
+		if err := u.auth.Apply(r); err != nil {
+			return nil, fmt.Errorf("注入上游 %s 凭证失败: %w", u.conf.ConfigKey, err)
+		}
+	}
+
+	// 查询参数中可能包含凭证，不记录
+	fullURL := (&url.URL{Scheme: r.URL.Scheme, Host: r.URL.Host, Path: r.URL.Path}).String()
+	record := audit.FromContext(r.Context())
+	record.SetHTTP(u.conf.ConfigKey, r.Method, fullURL)
+	ctx, span := tracing.Start(r.Context(), "HTTP "+r.Method, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(
+		attribute.String("http.request.method", r.Method),
+		attribute.String("url.full", fullURL),
+		attribute.String("server.address", r.URL.Hostname()),
+		tracing.ConfigKey(u.conf.ConfigKey),
+	)
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.HTTPUpstreamConnections.WithLabelValues(metrics.ConfigKey(u.conf.ConfigKey), strconv.FormatBool(info.Reused)).Inc()
+		},
+	})
+	r = r.WithContext(ctx)
+	// 向上游传递 traceparent
+	tracing.Inject(ctx, r.Header)
+	resp, err := u.client.Do(r)
+	if resp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		record.SetCode(resp.StatusCode)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	tracing.End(span, err)
+	return resp, err
+}
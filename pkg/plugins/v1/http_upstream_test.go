@@ -0,0 +1,186 @@
+package v1_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/open-dingtalk/ipaas-agent/pkg/plugins/v1"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHTTPUpstream_ResolveURL(t *testing.T) {
+	u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{ConfigKey: "erp", BaseURL: "https://erp.corp/api/v2?tenant=1"})
+	require.NoError(t, err)
+
+	resolved, err := u.ResolveURL("/orders/1?expand=items")
+	require.NoError(t, err)
+	require.Equal(t, "https://erp.corp/api/v2/orders/1?tenant=1&expand=items", resolved)
+
+	_, err = u.ResolveURL("https://evil.example.com/steal")
+	require.Error(t, err)
+
+	// 清理后的路径不能离开 base_url 的路径
+	resolved, err = u.ResolveURL("orders/../items/")
+	require.NoError(t, err)
+	require.Equal(t, "https://erp.corp/api/v2/items/?tenant=1", resolved)
+	for _, raw := range []string{"../../admin", "/%2e%2e/%2e%2e/admin", "..%2fv2-internal", "https://erp.corp/api/admin", "https://erp.corp/api/v2/../admin"} {
+		_, err = u.ResolveURL(raw)
+		require.Error(t, err, raw)
+	}
+}
+
+func TestHTTPUpstream_Redirect(t *testing.T) {
+	var leaked int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&leaked, 1)
+	}))
+	defer other.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, other.URL+"/steal", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/ping", http.StatusFound)
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-API-Key")))
+	}))
+	defer api.Close()
+
+	u, err := v1.NewHTTPUpstream(v1.HTTPUpstreamConfig{
+		ConfigKey: "erp",
+		BaseURL:   api.URL,
+		Headers:   map[string]string{"X-Tenant": "t1"},
+		Auth:      v1.HTTPAuthConfig{Type: "api_key", Key: "k0"},
+	})
+	require.NoError(t, err)
+	do := func(path string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, api.URL+path, nil)
+		require.NoError(t, err)
+		return u.Do(req)
+	}
+
+	// 同一主机内的重定向正常跟随
+	resp, err := do("/moved")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, "k0", string(body))
+
+	// 重定向到其它主机时拒绝，凭证不会发送过去
+	_, err = do("/away")
+	_, ok := v1.AsHTTPGuardError(err)
+	require.True(t, ok, err)
+	require.EqualValues(t, 0, atomic.LoadInt32(&leaked))
+}
+
+func TestHTTPUpstream_Auth(t *testing.T) {
+	var tokenRequests int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&tokenRequests, 1)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		require.Equal(t, "agent", r.Form.Get("client_id"))
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": 3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	var rejectToken atomic.Value
+	rejectToken.Store("")
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth != "" && auth == rejectToken.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if sig := r.Header.Get("X-Signature"); sig != "" {
+			bodyHash := sha256.Sum256(body)
+			mac := hmac.New(sha256.New, []byte("s3cret"))
+			fmt.Fprintf(mac, "%s\n%s\n%s\n%s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Timestamp"), hex.EncodeToString(bodyHash[:]))
+			require.Equal(t, hex.EncodeToString(mac.Sum(nil)), sig)
+			auth = "hmac-ok"
+		}
+		w.Write([]byte(auth + "|" + r.Header.Get("X-API-Key") + "|" + r.URL.Path))
+	}))
+	defer api.Close()
+
+	defer viper.Reset()
+	viper.Set("plugins.http", []map[string]interface{}{
+		{"config_key": "basic", "base_url": api.URL, "auth": map[string]interface{}{"type": "basic", "username": "u", "password": "p"}},
+		{"config_key": "bearer", "base_url": api.URL, "auth": map[string]interface{}{"type": "bearer", "token": "t0"}},
+		{"config_key": "apikey", "base_url": api.URL + "/v1", "auth": map[string]interface{}{"type": "api_key", "key": "k0"}},
+		{"config_key": "oauth", "base_url": api.URL, "auth": map[string]interface{}{"type": "oauth2", "token_url": tokenServer.URL, "client_id": "agent", "client_secret": "x"}},
+		{"config_key": "hmac", "base_url": api.URL, "auth": map[string]interface{}{"type": "hmac", "secret": "s3cret"}},
+	})
+	require.NoError(t, v1.LoadHTTPUpstreams())
+
+	call := func(key, method, body string) string {
+		resp, err := v1.HandleHTTPRequest(v1.HTTPRequest{ConfigKey: key, Method: method, URL: "/ping", Body: body})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp.Body
+	}
+
+	require.Equal(t, "Basic dTpw||/ping", call("basic", "GET", ""))
+	require.Equal(t, "Bearer t0||/ping", call("bearer", "GET", ""))
+	require.Equal(t, "|k0|/v1/ping", call("apikey", "GET", ""))
+	require.Equal(t, "hmac-ok||/ping", call("hmac", "POST", `{"a":1}`))
+
+	// 令牌被缓存复用
+	require.Equal(t, "Bearer token-1||/ping", call("oauth", "GET", ""))
+	require.Equal(t, "Bearer token-1||/ping", call("oauth", "GET", ""))
+	require.EqualValues(t, 1, atomic.LoadInt32(&tokenRequests))
+
+	// 令牌失效后自动刷新并重试
+	rejectToken.Store("Bearer token-1")
+	require.Equal(t, "Bearer token-2||/ping", call("oauth", "GET", ""))
+	require.EqualValues(t, 2, atomic.LoadInt32(&tokenRequests))
+
+	_, err := v1.HandleHTTPRequest(v1.HTTPRequest{ConfigKey: "missing", Method: "GET", URL: "/ping"})
+	require.Error(t, err)
+}
+
+func TestHandleHTTPRequestContext_Traceparent(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("traceparent")))
+	}))
+	defer api.Close()
+
+	defer viper.Reset()
+	viper.Set("plugins.http", []map[string]interface{}{
+		{"config_key": "traced", "base_url": api.URL},
+	})
+	require.NoError(t, v1.LoadHTTPUpstreams())
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+
+	// 未开启链路追踪时也会传递平台的 trace 上下文
+	resp, err := v1.HandleHTTPRequestContext(ctx, v1.HTTPRequest{ConfigKey: "traced", Method: "GET", URL: "/ping"})
+	require.NoError(t, err)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", resp.Body)
+
+	resp, err = v1.HandleHTTPRequest(v1.HTTPRequest{ConfigKey: "traced", Method: "GET", URL: "/ping"})
+	require.NoError(t, err)
+	require.Empty(t, resp.Body)
+}
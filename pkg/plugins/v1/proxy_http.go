@@ -1,8 +1,8 @@
 package v1
 
 import (
+	"bytes"
 	"context"
-	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -17,6 +17,25 @@ type HTTPRequest struct {
 	ContentType string            `json:"contentType,omitempty"`
 	URL         string            `json:"url,omitempty"`
 	Timeout     int               `json:"timeout,omitempty"`
+	// 命名上游 plugins.http[].config_key，指定后 url 为相对路径，凭证由本地注入
+	ConfigKey string `json:"configKey,omitempty"`
+	// 请求体编码，base64 表示 Body 为 base64 编码的二进制内容
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+	// 响应体编码，base64 | text，默认根据响应的 Content-Type 判断
+	ResponseEncoding string `json:"responseEncoding,omitempty"`
+	// multipart/form-data 字段和文件，设置后忽略 Body
+	Multipart []MultipartField `json:"multipart,omitempty"`
+	// 响应体大小上限，单位字节，只能小于上游配置的上限
+	MaxResponseSize int64 `json:"maxResponseSize,omitempty"`
+	// 响应模式，preview 表示只返回响应头和响应体的前 PreviewSize 个字节
+	ResponseMode string `json:"responseMode,omitempty"`
+	PreviewSize  int    `json:"previewSize,omitempty"`
+	// 是否解压 gzip/deflate 响应，默认 true
+	Decompress *bool `json:"decompress,omitempty"`
+	// 请求变量，用于渲染上游配置的请求体模板
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	// GraphQL 查询，设置后忽略 Method 和 Body
+	GraphQL *GraphQLRequest `json:"graphql,omitempty"`
 }
 
 type HTTPResponse struct {
@@ -28,17 +47,26 @@ type HTTPResponse struct {
 	// 新版
 	Headers map[string]string `json:"headers,omitempty"`
 	Content interface{}       `json:"content,omitempty"`
+	// 响应的 Content-Type，以及响应体的编码 (二进制内容为 base64)
+	ContentType  string `json:"contentType,omitempty"`
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+	// 响应体超过大小上限或处于预览模式时被截断
+	Truncated bool `json:"truncated,omitempty"`
+	// 上游声明的响应体长度，未知时不返回
+	ContentLength int64 `json:"contentLength,omitempty"`
+	// GraphQL 响应的 data 和 errors
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
 }
 
-var httpClient = &http.Client{}
+var httpClient = NewGuardedHTTPClient(0)
 
-func parseHTTPAgentResponse(resp *http.Response, respv1 *HTTPResponse) error {
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	logger.Log1.Infof("HTTP 请求成功: %s", string(body))
+func parseHTTPAgentResponse(resp *http.Response, respv1 *HTTPResponse, body []byte, encoding string) error {
+	logger.Log1.
+		WithField("status", resp.StatusCode).
+		WithField("size", len(body)).
+		WithField("truncated", respv1.Truncated).
+		Info("HTTP 请求成功")
 	respv1.Status = resp.Status
 	respv1.StatusCode = resp.StatusCode
 	respv1.Proto = resp.Proto
@@ -46,20 +74,54 @@ func parseHTTPAgentResponse(resp *http.Response, respv1 *HTTPResponse) error {
 	for k, v := range resp.Header {
 		respv1.Header[k] = strings.Join(v, ",")
 	}
-	respv1.Body = string(body)
+	respv1.ContentType = resp.Header.Get("Content-Type")
+	if resp.ContentLength > 0 {
+		respv1.ContentLength = resp.ContentLength
+	}
+	respv1.Body, respv1.BodyEncoding = EncodeResponseBody(body, respv1.ContentType, ResponseEncoding(resp, encoding))
 	return nil
 }
 
 func HandleHTTPRequest(ipaasHTTPRequest HTTPRequest) (*HTTPResponse, error) {
-	method := ipaasHTTPRequest.Method
-	url := ipaasHTTPRequest.URL
+	return HandleHTTPRequestContext(context.Background(), ipaasHTTPRequest)
+}
 
-	body := strings.NewReader(ipaasHTTPRequest.Body)
-	request, err := http.NewRequest(method, url, body)
+// HandleHTTPRequestContext 同 HandleHTTPRequest，请求使用 ctx 中的 trace 上下文，ctx 取消时中止请求
+func HandleHTTPRequestContext(ctx context.Context, ipaasHTTPRequest HTTPRequest) (*HTTPResponse, error) {
+	method := ipaasHTTPRequest.RequestMethod()
+	upstream, err := GetHTTPUpstream(ipaasHTTPRequest.ConfigKey)
+	if err != nil {
+		logger.Log1.Errorf("find http upstream error: %v", err)
+		return nil, err
+	}
+	url, err := upstream.ResolveURL(ipaasHTTPRequest.URL)
+	if err != nil {
+		logger.Log1.Errorf("resolve http url error: %v", err)
+		return nil, err
+	}
+
+	body, contentType, err := ipaasHTTPRequest.BuildBody()
+	if err != nil {
+		logger.Log1.Errorf("build http body error: %v", err)
+		return nil, err
+	}
+	body, transformedType, err := upstream.TransformRequestBody(&ipaasHTTPRequest, body)
+	if err != nil {
+		logger.Log1.Errorf("transform http body error: %v", err)
+		return nil, err
+	}
+	if transformedType != "" {
+		contentType = transformedType
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
 		logger.Log1.Errorf("create http request error: %v", err)
 		return nil, err
 	}
+	if err := GetHTTPGuard().CheckURL(request.URL); err != nil {
+		resp, _ := AsHTTPErrorResponse(err)
+		return resp, nil
+	}
 
 	for key, value := range ipaasHTTPRequest.Headers {
 		request.Header.Set(key, value)
@@ -68,25 +130,42 @@ func HandleHTTPRequest(ipaasHTTPRequest HTTPRequest) (*HTTPResponse, error) {
 	if ipaasHTTPRequest.ContentType != "" {
 		request.Header.Set("Content-Type", ipaasHTTPRequest.ContentType)
 	}
-	timeout := ipaasHTTPRequest.Timeout
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	timeout := upstream.Timeout(time.Duration(ipaasHTTPRequest.Timeout) * time.Second)
 	if timeout == 0 {
-		timeout = 5
+		timeout = 5 * time.Second
 	}
 
-	ctx, cancel := context.WithTimeout(request.Context(), time.Duration(timeout)*time.Second)
+	ctx, cancel := context.WithTimeout(request.Context(), timeout)
 	defer cancel()
 	request = request.WithContext(ctx)
-	response, err := httpClient.Do(request)
+	response, err := upstream.Do(request)
+	if resp, ok := AsHTTPErrorResponse(err); ok {
+		return resp, nil
+	}
 	if err != nil {
 		logger.Log1.Errorf("http request error: %v", err)
 		return nil, err
 	}
 	defer response.Body.Close()
-	var m HTTPResponse // HTTPResponse
-	err = parseHTTPAgentResponse(response, &m)
+	body, truncated, err := upstream.ReadResponse(response, &ipaasHTTPRequest)
+	if err != nil {
+		logger.Log1.Errorf("read http response error: %v", err)
+		return nil, err
+	}
+	if !truncated {
+		body = upstream.TransformResponse(response.Header, body)
+	}
+	m := HTTPResponse{Truncated: truncated}
+	err = parseHTTPAgentResponse(response, &m, body, ipaasHTTPRequest.ResponseEncoding)
 	if err != nil {
 		logger.Log1.Errorf("parse http response error: %v", err)
 		return nil, err
 	}
+	if ipaasHTTPRequest.GraphQL != nil && !truncated {
+		ApplyGraphQLResponse(&m, body)
+	}
 	return &m, err
 }
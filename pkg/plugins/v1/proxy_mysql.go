@@ -41,29 +41,48 @@ type Body struct {
 	ConfigKey    string            `json:"configKey"`
 }
 
+// MySQLConfig 旧版 mysql 插件使用的配置，与新版 mysql 插件共用 plugins.mysql
 type MySQLConfig struct {
-	Addr      string `mapstructure:"addr,omitempty" json:"addr,omitempty" `
-	Username  string `mapstructure:"username,omitempty" json:"username,omitempty" `
-	Password  string `mapstructure:"password,omitempty" json:"password,omitempty" `
-	Database  string `mapstructure:"database,omitempty" json:"database,omitempty" `
-	ConfigKey string `mapstructure:"config_key,omitempty" json:"config_key,omitempty" `
+	Host      string `mapstructure:"host,omitempty" json:"host,omitempty"`
+	Port      int    `mapstructure:"port,omitempty" json:"port,omitempty"`
+	Addr      string `mapstructure:"address,omitempty" json:"address,omitempty"`
+	Username  string `mapstructure:"user,omitempty" json:"user,omitempty"`
+	Password  string `mapstructure:"password,omitempty" json:"password,omitempty"`
+	Database  string `mapstructure:"database,omitempty" json:"database,omitempty"`
+	ConfigKey string `mapstructure:"config_key,omitempty" json:"config_key,omitempty"`
+}
+
+// Address 未配置 address 时使用 host 和 port 拼接
+func (c *MySQLConfig) Address() string {
+	if c.Addr != "" {
+		return c.Addr
+	}
+	port := c.Port
+	if port == 0 {
+		port = 3306
+	}
+	return fmt.Sprintf("%s:%d", c.Host, port)
 }
 
 func HandleMySQLProxyRequest(agentProtocol *IPaaSAgentProtocol) (interface{}, error) {
-	logger.Log1.Infof("handle mysql proxy request: %v", agentProtocol)
 	mysqlProtocol := &MySQLAgentProtocol{
 		ConfigKey:    agentProtocol.Body.ConfigKey,
 		ConfigParams: ConfigParams{Sql: agentProtocol.Body.ConfigParams["sql"]},
 	}
-	logger.Log1.Infof("mysql protocol: %v", mysqlProtocol)
+	logger.Log1.
+		WithField("configKey", mysqlProtocol.ConfigKey).
+		WithField("connectorId", agentProtocol.Headers.ConnectorId).
+		WithField("actionId", agentProtocol.Headers.ActionId).
+		Info("处理旧版 MySQL 代理请求")
+	logger.Log1.WithField("sql", mysqlProtocol.ConfigParams.Sql).Debug("执行SQL")
 
 	mySqlConfig := findConfigByKey(mysqlProtocol.ConfigKey)
 	if mySqlConfig == nil {
 		logger.Log1.Errorf("mysql config %s not found", mysqlProtocol.ConfigKey)
 		return nil, nil
 	}
-	logger.Log1.Infof("mysql config: %v", mySqlConfig)
-	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s", mySqlConfig.Username, mySqlConfig.Password, mySqlConfig.Addr, mySqlConfig.Database))
+	logger.Log1.WithField("address", mySqlConfig.Address()).WithField("database", mySqlConfig.Database).Info("找到配置")
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s", mySqlConfig.Username, mySqlConfig.Password, mySqlConfig.Address(), mySqlConfig.Database))
 	if err != nil {
 		panic(err)
 	}
@@ -112,9 +131,9 @@ func HandleMySQLProxyRequest(agentProtocol *IPaaSAgentProtocol) (interface{}, er
 				row[columns[i]] = col
 			}
 		}
-		logger.Log1.Infof("mysql query result: %v", row)
 		response = append(response, row)
 	}
+	logger.Log1.WithField("rows", len(response)).Info("SQL查询结束")
 
 	db.Close()
 	return response, nil
@@ -124,8 +143,8 @@ func findConfigByKey(key string) *MySQLConfig {
 	// 定义一个变量来存储 MySQL 配置
 	var mysqlConfigs []MySQLConfig
 
-	// 解析 MySQL 配置
-	if err := viper.UnmarshalKey("mysql", &mysqlConfigs); err != nil {
+	// 解析 MySQL 配置，旧版顶层 mysql 在加载配置时已合并到 plugins.mysql
+	if err := viper.UnmarshalKey("plugins.mysql", &mysqlConfigs); err != nil {
 		logger.Log1.Errorf("解析 MySQL 配置出错: %v", err)
 	}
 	// 打印 MySQL 配置
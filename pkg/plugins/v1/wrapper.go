@@ -42,6 +42,19 @@ func (df *DFWrap) GetDataJson() map[string]interface{} {
 	return df.dataJson
 }
 
+// GetHeaders 返回连接平台包装的 headers，包含连接器、动作和企业的 id，没有时为空
+func (df *DFWrap) GetHeaders() Headers {
+	var headers Headers
+	if raw, ok := df.GetDataJson()["headers"].(map[string]interface{}); ok {
+		headers.SpecVersion, _ = raw["specVersion"].(string)
+		headers.ConnectorCorpId, _ = raw["connectorCorpId"].(string)
+		headers.Type, _ = raw["type"].(string)
+		headers.ConnectorId, _ = raw["connectorId"].(string)
+		headers.ActionId, _ = raw["actionId"].(string)
+	}
+	return headers
+}
+
 // 获取 IPaaS DataFrame 的版本信息 默认 1.0
 func (df *DFWrap) GetDataVersion() string {
 	dataJson := df.GetDataJson()
@@ -11,6 +11,8 @@ import (
 type VersionPlugin struct {
 	Name            string `json:"name"`
 	ProtocolVersion string `json:"protocol_version"`
+	// 启用熔断的 HTTP 上游状态
+	HTTPBreakers []v1.BreakerStatus `json:"http_breakers,omitempty"`
 }
 
 func NewVersionPlugin() *VersionPlugin {
@@ -27,7 +29,9 @@ func (p *VersionPlugin) Init() error {
 
 func (p *VersionPlugin) HandleMessage(ctx context.Context, df *v1.DFWrap) (*payload.DataFrameResponse, error) {
 	// 处理消息，例如返回版本信息
-	return v1.NewSuccessDataFrameResponse(&p), nil
+	status := *p
+	status.HTTPBreakers = v1.HTTPBreakerStatus()
+	return v1.NewSuccessDataFrameResponse(&status), nil
 }
 
 func (p *VersionPlugin) Close() error {
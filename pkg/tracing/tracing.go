@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/open-dingtalk/ipaas-agent"
+
+// 未开启链路追踪时 span 不会被记录，但平台传入的 traceparent 仍会传递到上游 HTTP 请求
+var (
+	tracer     = otel.Tracer(instrumentationName)
+	propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+)
+
+// Options 导出配置
+type Options struct {
+	// OTLP/HTTP 接收地址，例如 http://127.0.0.1:4318
+	Endpoint       string
+	Headers        map[string]string
+	SampleRatio    float64
+	ServiceName    string
+	ServiceVersion string
+	Timeout        time.Duration
+}
+
+// Setup 设置全局的 TracerProvider，span 批量导出到 <endpoint>/v1/traces；
+// 返回的 shutdown 在退出前调用，导出剩余的 span
+func Setup(opts Options) (shutdown func(context.Context) error, err error) {
+	exporterOpts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpointURL(strings.TrimRight(opts.Endpoint, "/") + "/v1/traces"),
+	}
+	if len(opts.Headers) > 0 {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithHeaders(opts.Headers))
+	}
+	if opts.Timeout > 0 {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithTimeout(opts.Timeout))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+		semconv.ServiceVersion(opts.ServiceVersion),
+	)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithIDGenerator(messageIDGenerator{}),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+type messageIDKey struct{}
+
+// StartCallback 开始处理回调消息的 span。消息头中带有 traceparent 时以其为父级，
+// 否则由消息 id 生成 trace id，同一条消息的 trace id 固定，可以按消息 id 查找
+func StartCallback(ctx context.Context, name string, headers map[string]string, messageID string) (context.Context, trace.Span) {
+	carrier := make(propagation.MapCarrier, len(headers))
+	for k, v := range headers {
+		carrier[strings.ToLower(k)] = v
+	}
+	ctx = propagator.Extract(ctx, carrier)
+	if !trace.SpanContextFromContext(ctx).IsValid() && messageID != "" {
+		ctx = context.WithValue(ctx, messageIDKey{}, messageID)
+	}
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.MessagingMessageID(messageID)),
+	)
+}
+
+// Start 开始一个子 span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, opts...)
+}
+
+// End 结束 span，err 不为空时标记为失败
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject 将 ctx 中的 trace 上下文写入请求头 (traceparent、tracestate、baggage)
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// TraceID 返回 ctx 中的 trace id，没有时为空，用于在日志中关联
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// ConfigKey 本地配置 config_key 的属性
+func ConfigKey(key string) attribute.KeyValue {
+	return attribute.String("ipaas.config_key", key)
+}
+
+// MessageTraceID 由消息 id 生成的 trace id
+func MessageTraceID(messageID string) trace.TraceID {
+	sum := sha256.Sum256([]byte(messageID))
+	var id trace.TraceID
+	copy(id[:], sum[:])
+	return id
+}
+
+// messageIDGenerator 根 span 使用由消息 id 生成的 trace id，其余情况随机生成
+type messageIDGenerator struct{}
+
+func (messageIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	var traceID trace.TraceID
+	if messageID, ok := ctx.Value(messageIDKey{}).(string); ok {
+		traceID = MessageTraceID(messageID)
+	} else {
+		for !traceID.IsValid() {
+			binary.BigEndian.PutUint64(traceID[:8], rand.Uint64())
+			binary.BigEndian.PutUint64(traceID[8:], rand.Uint64())
+		}
+	}
+	return traceID, newSpanID()
+}
+
+func (messageIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	return newSpanID()
+}
+
+func newSpanID() trace.SpanID {
+	var id trace.SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collectorStub 接收 OTLP/HTTP protobuf 格式的 span
+type collectorStub struct {
+	*httptest.Server
+	mu      sync.Mutex
+	spans   []*tracepb.Span
+	headers http.Header
+}
+
+func newCollectorStub(t *testing.T) *collectorStub {
+	c := &collectorStub{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/traces", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req collectortrace.ExportTraceServiceRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+		c.mu.Lock()
+		c.headers = r.Header.Clone()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+		c.mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	return c
+}
+
+func (c *collectorStub) span(t *testing.T, name string) *tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("未收到 span %s", name)
+	return nil
+}
+
+func TestSetup(t *testing.T) {
+	collector := newCollectorStub(t)
+	defer collector.Close()
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	shutdown, err := Setup(Options{
+		Endpoint:    collector.URL,
+		Headers:     map[string]string{"X-Token": "abc"},
+		SampleRatio: 1,
+		ServiceName: "ipaas-agent",
+	})
+	require.NoError(t, err)
+
+	// 消息头中带有 traceparent 时以其为父级
+	parentTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx, span := StartCallback(context.Background(), "callback-with-parent", map[string]string{
+		"Traceparent": "00-" + parentTraceID + "-00f067aa0ba902b7-01",
+	}, "msg-1")
+	_, child := Start(ctx, "child")
+	child.End()
+	span.End()
+	require.Equal(t, parentTraceID, TraceID(ctx))
+
+	// 没有 trace 上下文时由消息 id 生成 trace id
+	ctx, span = StartCallback(context.Background(), "callback-from-message", nil, "msg-2")
+	span.End()
+	require.Equal(t, MessageTraceID("msg-2").String(), TraceID(ctx))
+
+	header := http.Header{}
+	Inject(ctx, header)
+	require.Contains(t, header.Get("traceparent"), MessageTraceID("msg-2").String())
+
+	require.NoError(t, shutdown(context.Background()))
+
+	withParent := collector.span(t, "callback-with-parent")
+	require.Equal(t, parentTraceID, hex.EncodeToString(withParent.TraceId))
+	require.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(withParent.ParentSpanId))
+	require.Equal(t, withParent.SpanId, collector.span(t, "child").ParentSpanId)
+
+	fromMessage := collector.span(t, "callback-from-message")
+	require.Equal(t, MessageTraceID("msg-2").String(), hex.EncodeToString(fromMessage.TraceId))
+	require.Empty(t, fromMessage.ParentSpanId)
+	require.Equal(t, "abc", collector.headers.Get("X-Token"))
+}
@@ -46,6 +46,11 @@ func updateHeader() {
 	headerArea.Update(headerContent)
 }
 
+// DisableOutput 关闭终端上的日志输出，只输出结果的命令使用，日志仍然写入文件
+func DisableOutput() {
+	pterm.DisableOutput()
+}
+
 func UpdateUISuccess(message string) {
 	// updateHeader() // 重新渲染头部
 	pterm.Success.Println(message)